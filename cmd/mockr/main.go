@@ -1,23 +1,29 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/abdillahi-nur/mockr/internal/config"
+	"github.com/abdillahi-nur/mockr/internal/render"
 	"github.com/abdillahi-nur/mockr/internal/server"
-	"github.com/fsnotify/fsnotify"
 )
 
 func printUsage() {
-	fmt.Fprintf(os.Stderr, "Usage: mockr start [flags] <configFile>\n")
+	fmt.Fprintf(os.Stderr, "Usage: mockr start [flags] <configFile|configDir>...\n")
+	fmt.Fprintf(os.Stderr, "       mockr validate [flags] <configFile|configDir>...\n")
 	fmt.Fprintf(os.Stderr, "\nFlags:\n")
 	fmt.Fprintf(os.Stderr, "  -host string\n")
 	fmt.Fprintf(os.Stderr, "        Host to bind to (default \"127.0.0.1\")\n")
@@ -25,10 +31,30 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "        Port to run the server on (default 3000)\n")
 	fmt.Fprintf(os.Stderr, "  -watch\n")
 	fmt.Fprintf(os.Stderr, "        Enable hot reload file watching (default true)\n")
+	fmt.Fprintf(os.Stderr, "  -watch-poll duration\n")
+	fmt.Fprintf(os.Stderr, "        Poll for config changes at this interval, e.g. 2s, instead of using\n")
+	fmt.Fprintf(os.Stderr, "        file system events (default 0 = disabled)\n")
+	fmt.Fprintf(os.Stderr, "  -symlink-policy string\n")
+	fmt.Fprintf(os.Stderr, "        Config symlink retargets to follow: same-dir, any or reject (default \"same-dir\")\n")
 	fmt.Fprintf(os.Stderr, "  -rate-limit float\n")
 	fmt.Fprintf(os.Stderr, "        Rate limit in requests per second (default 0 = disabled)\n")
 	fmt.Fprintf(os.Stderr, "  -burst int\n")
 	fmt.Fprintf(os.Stderr, "        Burst size for rate limiting (default 0; only used if rate-limit > 0)\n")
+	fmt.Fprintf(os.Stderr, "  -data-file string\n")
+	fmt.Fprintf(os.Stderr, "        Persist resource collections to this JSON file (default none)\n")
+	fmt.Fprintf(os.Stderr, "  -seed uint\n")
+	fmt.Fprintf(os.Stderr, "        Seed for fake data and uuids in templates (default 0 = random)\n")
+	fmt.Fprintf(os.Stderr, "  -strict\n")
+	fmt.Fprintf(os.Stderr, "        Refuse configs with errors or warnings, at startup and on reload\n")
+	fmt.Fprintf(os.Stderr, "  -admin-token string\n")
+	fmt.Fprintf(os.Stderr, "        Token for the admin endpoints (default $MOCKR_ADMIN_TOKEN; none = reload and runtime routes disabled)\n")
+	fmt.Fprintf(os.Stderr, "  -keep-runtime-routes\n")
+	fmt.Fprintf(os.Stderr, "        Keep routes added through the admin API when the config is reloaded\n")
+	fmt.Fprintf(os.Stderr, "\nValidate flags:\n")
+	fmt.Fprintf(os.Stderr, "  -strict\n")
+	fmt.Fprintf(os.Stderr, "        Treat warnings as errors\n")
+	fmt.Fprintf(os.Stderr, "  -format string\n")
+	fmt.Fprintf(os.Stderr, "        Output format: text or json (default \"text\")\n")
 }
 
 func main() {
@@ -37,7 +63,11 @@ func main() {
 		os.Exit(1)
 	}
 
-	if os.Args[1] != "start" {
+	switch os.Args[1] {
+	case "start":
+	case "validate":
+		os.Exit(validate(os.Args[2:]))
+	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
 		printUsage()
 		os.Exit(1)
@@ -47,70 +77,135 @@ func main() {
 	hostFlag := flag.String("host", "127.0.0.1", "Host to bind to")
 	portFlag := flag.Int("port", 3000, "Port to run the server on")
 	watchFlag := flag.Bool("watch", true, "Enable hot reload file watching")
+	watchPollFlag := flag.Duration("watch-poll", 0, "Poll for config changes at this interval instead of using file system events (default 0 = disabled)")
+	symlinkPolicyFlag := flag.String("symlink-policy", symlinkSameDir, "Config symlink retargets to follow: same-dir, any or reject")
 	rateLimitFlag := flag.Float64("rate-limit", 0, "Rate limit in requests per second (default 0 = disabled)")
 	burstFlag := flag.Int("burst", 0, "Burst size for rate limiting (default 0; only used if rate-limit > 0)")
+	dataFileFlag := flag.String("data-file", "", "Persist resource collections to this JSON file (default none)")
+	seedFlag := flag.Uint64("seed", 0, "Seed for fake data and uuids in templates (default 0 = random)")
+	strictFlag := flag.Bool("strict", false, "Refuse configs with errors or warnings, at startup and on reload")
+	keepRuntimeRoutesFlag := flag.Bool("keep-runtime-routes", false, "Keep routes added through the admin API when the config is reloaded")
+	adminTokenFlag := flag.String("admin-token", "", "Token for the admin endpoints (default $MOCKR_ADMIN_TOKEN; none = reload and runtime routes disabled)")
 
 	// Parse flags from os.Args[2:] (skip "start" command)
 	flag.CommandLine.Parse(os.Args[2:])
 
-	// Get config file from remaining args
-	args := flag.Args()
-	if len(args) < 1 {
+	// Get config files and directories from remaining args
+	configFiles := flag.Args()
+	if len(configFiles) < 1 {
 		fmt.Fprintf(os.Stderr, "Error: config file required\n")
 		printUsage()
 		os.Exit(1)
 	}
-	configFile := args[0]
 
 	host := *hostFlag
 	port := *portFlag
 	watch := *watchFlag
+	watchPoll := *watchPollFlag
+	symlinkPolicy := *symlinkPolicyFlag
 	rateLimit := *rateLimitFlag
 	burst := *burstFlag
+	dataFile := *dataFileFlag
+	seed := *seedFlag
+	strict := *strictFlag
+	keepRuntimeRoutes := *keepRuntimeRoutesFlag
+	adminToken := *adminTokenFlag
+	if adminToken == "" {
+		// Keeps the token out of the process list
+		adminToken = os.Getenv("MOCKR_ADMIN_TOKEN")
+	}
 
-	// Load and validate configuration
-	configResult, err := config.LoadConfig(configFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	if !validSymlinkPolicy(symlinkPolicy) {
+		fmt.Fprintf(os.Stderr, "Error: unsupported symlink policy '%s'\n", symlinkPolicy)
+		printUsage()
 		os.Exit(1)
 	}
 
-	// Print routes table
-	configResult.PrintRoutesTable()
+	// Make fake data reproducible, e.g. for CI runs
+	if seed != 0 {
+		render.Seed(seed)
+	}
 
-	// Resolve symlinks for security (needed for file watching)
-	resolvedConfigFile, err := filepath.EvalSymlinks(configFile)
+	// Load and validate configuration
+	configResult, err := config.LoadConfigs(configFiles)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error resolving config file path: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Convert valid routes to server.Route format
-	serverRoutes := make(map[string]server.Route)
-	for path, route := range configResult.ValidRoutes {
-		serverRoutes[path] = server.Route{
-			Method:   route.Method,
-			Status:   route.Status,
-			Delay:    route.Delay,
-			Response: route.Response,
-		}
+	// Print problems found and the routes table
+	configResult.LogIssues()
+	if err := checkConfig(configResult, strict); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	configResult.PrintRoutesTable()
+
+	// Convert valid routes and resources to server.Config format
+	serverConfig := toServerConfig(configResult)
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Create reload callback
-	onReload := func(newConfig map[string]server.Route) {
-		fmt.Printf("🔄 config reloaded (%d routes)\n", len(newConfig))
+	onReload := func(newConfig server.Config) {
+		fmt.Printf("🔄 config reloaded (%d routes)\n", len(newConfig.Routes))
 	}
 
 	// Start the server with rate limiting configuration
-	mockServer := server.New(serverRoutes, host, port, onReload)
+	mockServer := server.New(serverConfig, host, port, onReload)
 	if rateLimit > 0 {
 		mockServer.SetRateLimit(rateLimit, burst)
 		log.Printf("Rate limiting enabled: %.2f req/s, burst: %d", rateLimit, burst)
 	}
+	mockServer.SetKeepRuntimeRoutes(keepRuntimeRoutes)
+	mockServer.SetRouteParser(parseRuntimeRoute)
+	if dataFile != "" {
+		absDataFile, err := filepath.Abs(dataFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error resolving data file path: %v\n", err)
+			os.Exit(1)
+		}
+		if slices.Contains(configResult.ConfigFiles, absDataFile) {
+			fmt.Fprintf(os.Stderr, "Error: data file must not be a config file\n")
+			os.Exit(1)
+		}
+		// A data file written into a config directory would be loaded as a
+		// config file on the next start
+		if dataDir, err := filepath.EvalSymlinks(filepath.Dir(absDataFile)); err == nil &&
+			config.IsConfigFile(absDataFile) && slices.Contains(configResult.ConfigDirs, dataDir) {
+			fmt.Fprintf(os.Stderr, "Error: data file must not be inside a config directory\n")
+			os.Exit(1)
+		}
+		mockServer.SetDataFile(absDataFile)
+	}
+
+	// Every reload goes through the same path, whatever triggers it
+	configReloader := &reloader{configFiles: configFiles, mockServer: mockServer, strict: strict, last: configResult}
+	if watch {
+		configReloader.loaded = make(chan *config.ValidationResult, 1)
+	}
+	mockServer.SetAdminToken(adminToken)
+	mockServer.SetReloadHook(func() error {
+		_, err := configReloader.reload()
+		return err
+	})
+
+	// Reload on SIGHUP, e.g. after an orchestrator pushed a new config
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				log.Println("Received SIGHUP, reloading config")
+				configReloader.reload()
+			}
+		}
+	}()
 
 	// Channel to track file watcher lifecycle
 	watcherDone := make(chan struct{})
@@ -119,7 +214,10 @@ func main() {
 	if watch {
 		go func() {
 			defer close(watcherDone)
-			watchConfigFile(ctx, resolvedConfigFile, mockServer)
+			watchConfigFiles(ctx, configResult, configReloader, watchOptions{
+				poll:          watchPoll,
+				symlinkPolicy: symlinkPolicy,
+			})
 		}()
 	} else {
 		// If no watcher, close the channel immediately
@@ -170,121 +268,124 @@ func main() {
 	}
 }
 
-// watchConfigFile watches the config file for changes and reloads the server
-func watchConfigFile(ctx context.Context, configFile string, mockServer *server.Server) {
-	// Store the original resolved path for symlink safety
-	originalResolvedPath, err := filepath.EvalSymlinks(configFile)
-	if err != nil {
-		log.Printf("Error resolving config file path for watching: %v", err)
-		return
+// toServerConfig converts a validated config to server.Config format
+func toServerConfig(configResult *config.ValidationResult) server.Config {
+	return server.Config{
+		Routes:         toServerRoutes(configResult.ValidRoutes),
+		Resources:      configResult.Resources,
+		DefaultHeaders: configResult.DefaultHeaders,
 	}
+}
 
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Printf("Error creating watcher: %v", err)
-		return
+// parseRuntimeRoute decodes a route sent to the admin routes API and
+// validates it like a config route. Unknown fields are rejected so that typos
+// don't go unnoticed, and unlike in config files nothing is corrected: any
+// issue refuses the route.
+func parseRuntimeRoute(data []byte) (server.Route, error) {
+	var route config.Route
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&route); err != nil {
+		return server.Route{}, err
 	}
-	defer watcher.Close()
-
-	// Watch the directory containing the config file
-	configDir := "."
-	configFileName := configFile
-	if lastSlash := len(configFile) - 1; lastSlash >= 0 {
-		for i := lastSlash; i >= 0; i-- {
-			if configFile[i] == '/' || configFile[i] == '\\' {
-				configDir = configFile[:i]
-				configFileName = configFile[i+1:]
-				break
+
+	route, issues := config.ValidateRoute(route)
+	if len(issues) > 0 {
+		problems := make([]string, 0, len(issues))
+		for _, issue := range issues {
+			// Messages end with what a config load does about the issue,
+			// such as ", skipping", which doesn't apply here
+			problem := issue.Message
+			if i := strings.LastIndex(problem, ", "); i >= 0 {
+				problem = problem[:i]
 			}
+			problems = append(problems, problem)
 		}
+		return server.Route{}, errors.New(strings.Join(problems, "; "))
 	}
+	return toServerRoutes([]config.Route{route})[0], nil
+}
 
-	err = watcher.Add(configDir)
-	if err != nil {
-		log.Printf("Error adding directory to watcher: %v", err)
-		return
-	}
-
-	log.Printf("Watching config file: %s", configFileName)
-
-	// Debounce timer to avoid multiple reloads
-	var reloadTimer *time.Timer
+// toServerRoutes converts validated config routes to server.Route format
+func toServerRoutes(routes []config.Route) []server.Route {
+	serverRoutes := make([]server.Route, 0, len(routes))
+	for _, route := range routes {
+		serverRoute := server.Route{
+			Path:     route.Path,
+			Method:   route.Method,
+			Status:   route.Status,
+			Delay:    route.Delay,
+			Priority: route.Priority,
+			Template: route.Template,
+			Response: route.Response,
+			Headers:  route.Headers,
 
-	for {
-		select {
-		case <-ctx.Done():
-			// Context cancelled, stop watching
-			log.Println("Stopping file watcher due to shutdown signal")
-			return
-		case event, ok := <-watcher.Events:
-			if !ok {
-				return
-			}
+			Body:        route.Body,
+			BodyBase64:  route.BodyBase64,
+			ContentType: route.ContentType,
 
-			// Check if the config file was modified
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				// Extract filename from event path
-				eventFile := event.Name
-				if lastSlash := len(eventFile) - 1; lastSlash >= 0 {
-					for i := lastSlash; i >= 0; i-- {
-						if eventFile[i] == '/' || eventFile[i] == '\\' {
-							eventFile = eventFile[i+1:]
-							break
-						}
-					}
-				}
-
-				if eventFile == configFileName {
-					// Security check: verify the resolved path hasn't changed (symlink safety)
-					currentResolvedPath, err := filepath.EvalSymlinks(configFile)
-					if err != nil {
-						log.Printf("Error resolving config file path during reload: %v", err)
-						continue
-					}
-					if currentResolvedPath != originalResolvedPath {
-						log.Printf("Warning: Config file path resolution changed, ignoring reload for security")
-						continue
-					}
-
-					// Debounce reload to avoid multiple rapid reloads
-					if reloadTimer != nil {
-						reloadTimer.Stop()
-					}
-					reloadTimer = time.AfterFunc(200*time.Millisecond, func() {
-						reloadConfig(configFile, mockServer)
-					})
-				}
-			}
+			ResponseMode: route.ResponseMode,
+			Collection:   route.Collection,
 
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("Watcher error: %v", err)
+			Scenario:      route.Scenario,
+			RequiredState: route.RequiredState,
+			NewState:      route.NewState,
+		}
+		if route.Match != nil {
+			serverRoute.Match = toServerMatch(route.Match)
+		}
+		for _, entry := range route.Responses {
+			serverRoute.Responses = append(serverRoute.Responses, server.ResponseEntry{
+				Status:      entry.Status,
+				Delay:       entry.Delay,
+				Headers:     entry.Headers,
+				Response:    entry.Response,
+				Body:        entry.Body,
+				BodyBase64:  entry.BodyBase64,
+				ContentType: entry.ContentType,
+			})
 		}
+		serverRoutes = append(serverRoutes, serverRoute)
 	}
+	return serverRoutes
 }
 
-// reloadConfig reloads the configuration and updates the server
-func reloadConfig(configFile string, mockServer *server.Server) {
-	// Load and validate configuration using the config package
-	configResult, err := config.LoadConfig(configFile)
-	if err != nil {
-		log.Printf("Error loading config file during reload: %v", err)
-		return
+// toServerMatch converts config request matchers to server.Match format
+func toServerMatch(match *config.Match) *server.Match {
+	return &server.Match{
+		PathRegex: match.PathRegex,
+		Query:     toServerValueMatchers(match.Query),
+		Headers:   toServerValueMatchers(match.Headers),
+		Cookies:   toServerValueMatchers(match.Cookies),
+		Body:      toServerBodyMatcher(match.Body),
 	}
+}
 
-	// Convert to server.Route format
-	serverRoutes := make(map[string]server.Route)
-	for path, route := range configResult.ValidRoutes {
-		serverRoutes[path] = server.Route{
-			Method:   route.Method,
-			Status:   route.Status,
-			Delay:    route.Delay,
-			Response: route.Response,
-		}
+// toServerBodyMatcher converts config body matchers
+func toServerBodyMatcher(body *config.BodyMatcher) *server.BodyMatcher {
+	if body == nil {
+		return nil
+	}
+	return &server.BodyMatcher{
+		EqualsJSON:   body.EqualsJSON,
+		ContainsJSON: body.ContainsJSON,
+		JSONPath:     toServerValueMatchers(body.JSONPath),
+		Regex:        body.Regex,
 	}
+}
 
-	// Reload server configuration
-	mockServer.ReloadConfig(serverRoutes)
+// toServerValueMatchers converts named config value matchers
+func toServerValueMatchers(matchers map[string]config.ValueMatcher) map[string]server.ValueMatcher {
+	if matchers == nil {
+		return nil
+	}
+	serverMatchers := make(map[string]server.ValueMatcher, len(matchers))
+	for name, m := range matchers {
+		serverMatchers[name] = server.ValueMatcher{
+			Equals:  m.Equals,
+			Present: m.Present,
+			Regex:   m.Regex,
+		}
+	}
+	return serverMatchers
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/abdillahi-nur/mockr/internal/config"
+	"github.com/abdillahi-nur/mockr/internal/server"
+)
+
+// reloader reloads the config into the server. The watcher, SIGHUP and the
+// admin reload endpoint all reload through it.
+type reloader struct {
+	configFiles []string
+	mockServer  *server.Server
+
+	// strict refuses configs with warnings
+	strict bool
+
+	// mu serializes reloads, so that a slow load never replaces a newer one,
+	// and guards last, the config being served
+	mu   sync.Mutex
+	last *config.ValidationResult
+
+	// loaded receives every config loaded, refused or not, so that the
+	// watcher follows the files it references whatever triggered the reload.
+	// It holds the latest one only and is nil when not watching.
+	loaded chan *config.ValidationResult
+}
+
+// reload reloads the configuration and updates the server. Reloads are all
+// or nothing: if the config cannot be loaded or checkConfig refuses it, the
+// server keeps serving the last good config, the failure shows in its status
+// and an error is returned.
+//
+// The loaded config is returned even if it was refused, so that the files it
+// references are watched for a fix. It is nil if the config could not be
+// loaded.
+func (r *reloader) reload() (*config.ValidationResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Load and validate configuration using the config package
+	configResult, err := config.LoadConfigs(r.configFiles)
+	if err != nil {
+		return nil, r.failed(err)
+	}
+	configResult.LogIssues()
+
+	r.publish(configResult)
+
+	if err := checkConfig(configResult, r.strict); err != nil {
+		return configResult, r.failed(err)
+	}
+
+	// Convert to server.Config format and reload server configuration. The
+	// server reports its own failures in its status.
+	if err := r.mockServer.ReloadConfig(toServerConfig(configResult)); err != nil {
+		printReloadFailure(err)
+		return configResult, err
+	}
+	printRouteDiff(config.DiffRoutes(r.last, configResult))
+	r.last = configResult
+	return configResult, nil
+}
+
+// checkConfig applies the policy shared by startup and reloads: invalid
+// parts of a config are skipped and the rest is served, unless strict mode
+// refuses any error or warning
+func checkConfig(configResult *config.ValidationResult, strict bool) error {
+	if errs, warnings := configResult.Count(); strict && errs+warnings > 0 {
+		return fmt.Errorf("config is invalid in strict mode: %d errors, %d warnings", errs, warnings)
+	}
+	return nil
+}
+
+// publish hands a loaded config to the watcher without waiting for it,
+// replacing one it has not picked up yet. The caller must hold r.mu.
+func (r *reloader) publish(configResult *config.ValidationResult) {
+	if r.loaded == nil {
+		return
+	}
+	for {
+		select {
+		case r.loaded <- configResult:
+			return
+		default:
+			select {
+			case <-r.loaded:
+			default:
+			}
+		}
+	}
+}
+
+// failed reports a reload that was refused before reaching the server
+func (r *reloader) failed(err error) error {
+	printReloadFailure(err)
+	r.mockServer.ReportReloadFailure(err)
+	return err
+}
+
+// printReloadFailure prints why a reload was refused
+func printReloadFailure(err error) {
+	fmt.Printf("❌ config reload failed, keeping the last good config: %v\n", err)
+}
+
+// printRouteDiff prints the routes a reload added, removed and changed
+func printRouteDiff(diff config.RouteDiff) {
+	if diff.Empty() {
+		fmt.Println("   no route changes")
+		return
+	}
+	for _, route := range diff.Added {
+		fmt.Printf("   + %s\n", route)
+	}
+	for _, route := range diff.Removed {
+		fmt.Printf("   - %s\n", route)
+	}
+	for _, route := range diff.Changed {
+		fmt.Printf("   ~ %s\n", route)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/abdillahi-nur/mockr/internal/config"
+)
+
+// validationReport is the machine-readable output of the validate command
+type validationReport struct {
+	Valid     bool           `json:"valid"`
+	Strict    bool           `json:"strict"`
+	Errors    int            `json:"errors"`
+	Warnings  int            `json:"warnings"`
+	Routes    int            `json:"routes"`
+	Resources int            `json:"resources"`
+	Issues    []config.Issue `json:"issues"`
+}
+
+// validate checks config files without starting the server and reports every
+// problem found. It returns the exit code: 0 if the config is valid, 1 if it
+// has errors (or warnings in strict mode) and 2 on usage errors.
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = printUsage
+	strictFlag := fs.Bool("strict", false, "Treat warnings as errors")
+	formatFlag := fs.String("format", "text", "Output format: text or json")
+
+	// Flags may follow the config files, as is common in CI scripts
+	var configFiles []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		configFiles = append(configFiles, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(configFiles) < 1 {
+		fmt.Fprintf(os.Stderr, "Error: config file required\n")
+		printUsage()
+		return 2
+	}
+	if *formatFlag != "text" && *formatFlag != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unsupported format '%s'\n", *formatFlag)
+		return 2
+	}
+
+	report := validationReport{Strict: *strictFlag, Issues: []config.Issue{}}
+	configResult, err := config.LoadConfigs(configFiles)
+	if err != nil {
+		// The config cannot be loaded at all
+		issue := config.Issue{Severity: config.SeverityError, Message: err.Error()}
+		var parseErr *config.ParseError
+		if errors.As(err, &parseErr) {
+			issue.File, issue.Line, issue.Message = parseErr.File, parseErr.Line, parseErr.Err.Error()
+		}
+		report.Issues = append(report.Issues, issue)
+	} else {
+		report.Routes = len(configResult.ValidRoutes)
+		report.Resources = len(configResult.Resources)
+		report.Issues = append(report.Issues, configResult.Issues...)
+	}
+
+	for i := range report.Issues {
+		issue := &report.Issues[i]
+		if issue.Severity == config.SeverityWarning && report.Strict {
+			issue.Severity = config.SeverityError
+		}
+		if issue.Severity == config.SeverityError {
+			report.Errors++
+		} else {
+			report.Warnings++
+		}
+	}
+	report.Valid = report.Errors == 0
+
+	if *formatFlag == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(os.Stdout, report)
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// printReport prints a validation report for humans, one issue per line as
+// "file:line: severity: message"
+func printReport(w io.Writer, report validationReport) {
+	for _, issue := range report.Issues {
+		if loc := issue.Location(); loc != "" {
+			fmt.Fprintf(w, "%s: %s: %s\n", loc, issue.Severity, issue.Message)
+		} else {
+			fmt.Fprintf(w, "%s: %s\n", issue.Severity, issue.Message)
+		}
+	}
+
+	if report.Valid {
+		fmt.Fprintf(w, "✅ config is valid: %d routes, %d resources, %d warnings\n", report.Routes, report.Resources, report.Warnings)
+	} else {
+		fmt.Fprintf(w, "❌ config is invalid: %d errors, %d warnings\n", report.Errors, report.Warnings)
+	}
+}
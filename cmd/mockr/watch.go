@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdillahi-nur/mockr/internal/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Symlink policies for config files whose symlink is retargeted while
+// watching, e.g. by a Kubernetes ConfigMap update
+const (
+	// symlinkSameDir follows retargets to files inside the directory of the
+	// config path given, which covers ConfigMap volumes
+	symlinkSameDir = "same-dir"
+	// symlinkAny follows any retarget
+	symlinkAny = "any"
+	// symlinkReject ignores changes once a config path resolves elsewhere
+	symlinkReject = "reject"
+)
+
+// watchOptions configures config file watching
+type watchOptions struct {
+	// poll checks files for changes at this interval instead of relying on
+	// file system events, for network and container file systems
+	poll time.Duration
+
+	// symlinkPolicy is one of symlinkSameDir, symlinkAny or symlinkReject
+	symlinkPolicy string
+}
+
+// validSymlinkPolicy reports whether policy is a known symlink policy
+func validSymlinkPolicy(policy string) bool {
+	switch policy {
+	case symlinkSameDir, symlinkAny, symlinkReject:
+		return true
+	}
+	return false
+}
+
+// configWatcher reloads the server when the config changes. It watches the
+// config paths as given, the files they include, the body files they
+// reference and the config directories given.
+type configWatcher struct {
+	configFiles []string
+	reloader    *reloader
+	opts        watchOptions
+
+	// mu serializes reloads and guards resolved, which maps each config path
+	// given to the path it resolves to
+	mu       sync.Mutex
+	resolved map[string]string
+
+	// files are the absolute paths of the files whose changes trigger a
+	// reload, and configDirs the directories where adding or removing a
+	// config file triggers one. Both are replaced after every reload.
+	files      map[string]bool
+	configDirs map[string]bool
+
+	// dataFiles are the absolute paths of the resource data file, as given
+	// and with its directory's symlinks resolved. Writes to it and to its
+	// temporary files must never trigger a reload.
+	dataFiles []string
+
+	reloadTimer *time.Timer
+}
+
+// watchConfigFiles watches the config files and directories, the files they
+// include and the body files they reference for changes and reloads the server
+func watchConfigFiles(ctx context.Context, configResult *config.ValidationResult, r *reloader, opts watchOptions) {
+	configFiles := r.configFiles
+	w := &configWatcher{
+		configFiles: configFiles,
+		reloader:    r,
+		opts:        opts,
+		resolved:    make(map[string]string, len(configFiles)),
+		files:       make(map[string]bool),
+		configDirs:  make(map[string]bool),
+	}
+
+	// Store the original resolved paths for symlink safety
+	for _, configFile := range configFiles {
+		resolvedPath, err := filepath.EvalSymlinks(configFile)
+		if err != nil {
+			log.Printf("Error resolving config file path for watching: %v", err)
+			return
+		}
+		w.resolved[configFile] = resolvedPath
+	}
+
+	if dataFile := r.mockServer.DataFile(); dataFile != "" {
+		w.dataFiles = append(w.dataFiles, dataFile)
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(dataFile)); err == nil {
+			w.dataFiles = append(w.dataFiles, filepath.Join(dir, filepath.Base(dataFile)))
+		}
+	}
+	w.update(configResult)
+
+	for _, configFile := range configFiles {
+		if info, err := os.Stat(configFile); err == nil && info.IsDir() {
+			log.Printf("Watching config directory: %s", filepath.Base(configFile))
+		} else {
+			log.Printf("Watching config file: %s", filepath.Base(configFile))
+		}
+	}
+
+	if opts.poll > 0 {
+		log.Printf("Polling for config changes every %v", opts.poll)
+		w.poll(ctx)
+	} else {
+		w.watch(ctx)
+	}
+}
+
+// update replaces the watched files with those of a loaded config
+func (w *configWatcher) update(result *config.ValidationResult) {
+	clear(w.files)
+	clear(w.configDirs)
+	for _, file := range append(slices.Clone(result.ConfigFiles), result.BodyFiles...) {
+		w.files[file] = true
+	}
+	for _, dir := range result.ConfigDirs {
+		w.configDirs[dir] = true
+	}
+}
+
+// dirs returns the directories to watch: those of the config paths as given,
+// so that symlink swaps next to them are seen, and those of every watched
+// file and config directory
+func (w *configWatcher) dirs() map[string]bool {
+	dirs := make(map[string]bool)
+	for _, configFile := range w.configFiles {
+		if abs, err := filepath.Abs(configFile); err == nil {
+			dirs[filepath.Dir(abs)] = true
+		}
+	}
+	for file := range w.files {
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range w.configDirs {
+		dirs[dir] = true
+	}
+	return dirs
+}
+
+// relevant reports whether a change to path may change the config
+func (w *configWatcher) relevant(path string) bool {
+	name := filepath.Base(path)
+	for _, dataFile := range w.dataFiles {
+		if path == dataFile || (filepath.Dir(path) == filepath.Dir(dataFile) && strings.HasPrefix(name, "."+filepath.Base(dataFile)+".tmp-")) {
+			return false
+		}
+	}
+	if w.files[path] {
+		return true
+	}
+
+	// Config paths as given, and in their directory the "..data" style
+	// symlinks Kubernetes swaps to update ConfigMap volumes
+	for _, configFile := range w.configFiles {
+		abs, err := filepath.Abs(configFile)
+		if err != nil {
+			continue
+		}
+		if path == abs || (filepath.Dir(path) == filepath.Dir(abs) && strings.HasPrefix(name, "..")) {
+			return true
+		}
+	}
+
+	// Config files added to or removed from config directories
+	return w.configDirs[filepath.Dir(path)] && config.IsConfigFile(name) && !strings.HasPrefix(name, ".")
+}
+
+// watch reloads on file system events. Directories are watched rather than
+// files, so files replaced by editors saving through a rename keep being
+// watched, and directories that are removed are watched again once they
+// reappear after a reload.
+func (w *configWatcher) watch(ctx context.Context) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Error creating watcher: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	watchedDirs := make(map[string]bool)
+	addWatches := func() {
+		wanted := w.dirs()
+		for dir := range watchedDirs {
+			if !wanted[dir] {
+				watcher.Remove(dir)
+				delete(watchedDirs, dir)
+			}
+		}
+		for dir := range wanted {
+			if watchedDirs[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				log.Printf("Error adding directory to watcher: %v", err)
+				continue
+			}
+			watchedDirs[dir] = true
+		}
+	}
+	addWatches()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Context cancelled, stop watching
+			log.Println("Stopping file watcher due to shutdown signal")
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			path, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+
+			// A removed directory loses its watch; forget it so that it is
+			// watched again once it exists
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && watchedDirs[path] {
+				delete(watchedDirs, path)
+			}
+
+			// Writes, creates, removes and renames all count: editors saving
+			// atomically write a temporary file and rename it over the config
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 && w.relevant(path) {
+				w.scheduleReload()
+			}
+
+		case result := <-w.reloader.loaded:
+			w.update(result)
+			addWatches()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
+		}
+	}
+}
+
+// poll reloads when the state of the watched files changes between checks
+func (w *configWatcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.poll)
+	defer ticker.Stop()
+
+	last := w.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping file watcher due to shutdown signal")
+			return
+		case <-ticker.C:
+			if current := w.snapshot(); current != last {
+				last = current
+				w.scheduleReload()
+			}
+		case result := <-w.reloader.loaded:
+			w.update(result)
+			last = w.snapshot()
+		}
+	}
+}
+
+// snapshot describes the state of the watched files: where each resolves to,
+// its size and modification time, and the config files of config directories
+func (w *configWatcher) snapshot() string {
+	var b strings.Builder
+	describe := func(path string) {
+		resolved, _ := filepath.EvalSymlinks(path)
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "%s>%s:%d:%d\n", path, resolved, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&b, "%s:missing\n", path)
+		}
+	}
+
+	for _, configFile := range w.configFiles {
+		describe(configFile)
+	}
+	for _, file := range slices.Sorted(maps.Keys(w.files)) {
+		describe(file)
+	}
+	for _, dir := range slices.Sorted(maps.Keys(w.configDirs)) {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if w.relevant(filepath.Join(dir, entry.Name())) {
+				describe(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return b.String()
+}
+
+// scheduleReload reloads after a short delay, so that a burst of changes
+// such as an atomic save or a ConfigMap update reloads once
+func (w *configWatcher) scheduleReload() {
+	if w.reloadTimer != nil {
+		w.reloadTimer.Stop()
+	}
+	w.reloadTimer = time.AfterFunc(200*time.Millisecond, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if !w.checkSymlinks() {
+			return
+		}
+		w.reloader.reload()
+	})
+}
+
+// checkSymlinks applies the symlink policy to config paths that resolve to a
+// different file than before. It reports whether the reload may go ahead.
+// The caller must hold w.mu.
+func (w *configWatcher) checkSymlinks() bool {
+	for _, configFile := range w.configFiles {
+		currentResolvedPath, err := filepath.EvalSymlinks(configFile)
+		if err != nil {
+			log.Printf("Error resolving config file path during reload: %v", err)
+			return false
+		}
+		originalResolvedPath := w.resolved[configFile]
+		if currentResolvedPath == originalResolvedPath {
+			continue
+		}
+
+		switch w.opts.symlinkPolicy {
+		case symlinkAny:
+		case symlinkSameDir:
+			if !insideDir(currentResolvedPath, filepath.Dir(configFile)) {
+				log.Printf("Warning: Config file %s now resolves to %s outside its directory, ignoring reload for security", configFile, currentResolvedPath)
+				return false
+			}
+		default:
+			log.Printf("Warning: Config file path resolution changed, ignoring reload for security")
+			return false
+		}
+
+		// Later changes are checked against the new target
+		log.Printf("Config file %s now resolves to %s", configFile, currentResolvedPath)
+		w.resolved[configFile] = currentResolvedPath
+	}
+	return true
+}
+
+// insideDir reports whether path is inside dir once dir's symlinks are
+// resolved
+func insideDir(path, dir string) bool {
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(resolvedDir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -5,6 +5,7 @@ go 1.25.1
 require (
 	github.com/fsnotify/fsnotify v1.9.0
 	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require golang.org/x/sys v0.13.0 // indirect
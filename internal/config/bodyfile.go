@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodyFileSize limits the size of a single body file (10MB)
+const maxBodyFileSize = 10 << 20
+
+// loadBodyFiles replaces the body files of a route and its response entries
+// with their contents. It returns the paths of the body files referenced,
+// including ones that failed to load, so they can be watched for changes.
+func loadBodyFiles(route *Route, baseDir string) ([]string, error) {
+	var paths []string
+
+	if route.BodyFile != "" {
+		path, err := loadBodyFile(baseDir, route.BodyFile, &route.Response, &route.Body, &route.BodyBase64, &route.ContentType)
+		if path != "" {
+			paths = append(paths, path)
+		}
+		if err != nil {
+			return paths, err
+		}
+	}
+
+	for i := range route.Responses {
+		entry := &route.Responses[i]
+		if entry.BodyFile == "" {
+			continue
+		}
+		path, err := loadBodyFile(baseDir, entry.BodyFile, &entry.Response, &entry.Body, &entry.BodyBase64, &entry.ContentType)
+		if path != "" {
+			paths = append(paths, path)
+		}
+		if err != nil {
+			return paths, fmt.Errorf("response %d: %w", i+1, err)
+		}
+	}
+
+	return paths, nil
+}
+
+// loadBodyFile reads a body file relative to baseDir into the matching kind
+// of body: JSON files become a JSON response, other text files a raw body
+// and anything else a base64 body. The content type is inferred from the
+// file extension unless set explicitly.
+func loadBodyFile(baseDir, name string, response *interface{}, body, bodyBase64, contentType *string) (string, error) {
+	// Body files must stay inside the config directory, like the config file
+	// itself the final path is checked after resolving symlinks
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("bodyFile '%s' must be a relative path inside the config directory", name)
+	}
+	path := filepath.Join(baseDir, name)
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path, fmt.Errorf("error resolving bodyFile '%s': %w", name, err)
+	}
+	if rel, err := filepath.Rel(baseDir, resolved); err != nil || !filepath.IsLocal(rel) {
+		return path, fmt.Errorf("bodyFile '%s' resolves outside the config directory", name)
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return path, fmt.Errorf("error reading bodyFile '%s': %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return path, fmt.Errorf("bodyFile '%s' is not a regular file", name)
+	}
+	if info.Size() > maxBodyFileSize {
+		return path, fmt.Errorf("bodyFile '%s' exceeds %d bytes", name, maxBodyFileSize)
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return path, fmt.Errorf("error reading bodyFile '%s': %w", name, err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	switch {
+	case ext == ".json" && *contentType == "":
+		if err := json.Unmarshal(data, response); err != nil {
+			return path, fmt.Errorf("error parsing bodyFile '%s': %w", name, err)
+		}
+		return path, nil
+	case utf8.Valid(data):
+		*body = string(data)
+	default:
+		*bodyBase64 = base64.StdEncoding.EncodeToString(data)
+	}
+
+	if *contentType == "" {
+		*contentType = mime.TypeByExtension(ext)
+	}
+	if *contentType == "" {
+		*contentType = http.DetectContentType(data)
+	}
+	return path, nil
+}
@@ -1,93 +1,566 @@
 package config
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"maps"
+	"slices"
 	"strings"
+
+	"github.com/abdillahi-nur/mockr/internal/pathpattern"
+	"github.com/abdillahi-nur/mockr/internal/render"
 )
 
 // Route represents a mock API route configuration
 type Route struct {
+	Path     string      `json:"path,omitempty"`
 	Method   string      `json:"method"`
 	Status   int         `json:"status,omitempty"`
 	Delay    int         `json:"delay,omitempty"`
+	Priority int         `json:"priority,omitempty"`
+	Match    *Match      `json:"match,omitempty"`
+	Template bool        `json:"template,omitempty"`
 	Response interface{} `json:"response"`
+
+	// Body is sent as is instead of a JSON response, and BodyBase64 is
+	// decoded and sent as binary. ContentType overrides the content type,
+	// which defaults to JSON, plain text or binary accordingly.
+	Body        string `json:"body,omitempty"`
+	BodyBase64  string `json:"bodyBase64,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+
+	// BodyFile loads the body from a file relative to the config file. The
+	// content type is inferred from the file extension.
+	BodyFile string `json:"bodyFile,omitempty"`
+
+	// Headers are added to every response of the route
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Responses replaces Response with several responses picked per call
+	// according to ResponseMode
+	Responses    []ResponseEntry `json:"responses,omitempty"`
+	ResponseMode string          `json:"responseMode,omitempty"`
+
+	// Collection enables filtering, sorting and pagination of array
+	// responses through query parameters
+	Collection bool `json:"collection,omitempty"`
+
+	// Scenario names a state machine the route belongs to. The route only
+	// matches while the scenario is in RequiredState (when set) and moves it
+	// to NewState (when set) once served. Scenarios start in state "Started".
+	Scenario      string `json:"scenario,omitempty"`
+	RequiredState string `json:"requiredState,omitempty"`
+	NewState      string `json:"newState,omitempty"`
+
+	// source is the config file defining the route, line the line it starts
+	// on, and dir the absolute directory that body files are relative to.
+	// offset is the route's offset in the routes value while decoding.
+	source string
+	line   int
+	dir    string
+	offset int64
+}
+
+// ResponseEntry is one of several responses of a route. Status, delay and
+// content type default to the route's own.
+type ResponseEntry struct {
+	Status      int               `json:"status,omitempty"`
+	Delay       int               `json:"delay,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Response    interface{}       `json:"response"`
+	Body        string            `json:"body,omitempty"`
+	BodyBase64  string            `json:"bodyBase64,omitempty"`
+	BodyFile    string            `json:"bodyFile,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+}
+
+// Response modes of routes with several responses
+const (
+	// ModeSequence advances through the responses per call and then sticks
+	// on the last one
+	ModeSequence = "sequence"
+	// ModeCycle advances through the responses per call and starts over
+	ModeCycle = "cycle"
+	// ModeRandom picks a random response per call
+	ModeRandom = "random"
+)
+
+// Routes is the ordered list of configured routes. It can be written either
+// as a JSON array of routes carrying their own "path", or as an object keyed
+// by path. The object form may repeat a path so that one path can carry
+// several methods, which a plain map would silently collapse.
+type Routes []Route
+
+// UnmarshalJSON decodes routes from either the array or the object form.
+// Errors are wrapped in a routesError as their offsets are relative to data.
+func (rs *Routes) UnmarshalJSON(data []byte) error {
+	if err := rs.unmarshal(data); err != nil {
+		return &routesError{err: err}
+	}
+	return nil
+}
+
+// unmarshal decodes routes from either the array or the object form
+func (rs *Routes) unmarshal(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*rs = nil
+		return nil
+	}
+	delim, _ := tok.(json.Delim)
+	if delim != '{' && delim != '[' {
+		return fmt.Errorf("routes must be an object or an array")
+	}
+
+	// Routes are decoded one by one to record their offsets for messages
+	var list []Route
+	for dec.More() {
+		path := ""
+		if delim == '{' {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			path, _ = keyTok.(string)
+		}
+
+		var route Route
+		start := valueOffset(trimmed, dec.InputOffset())
+		if err := dec.Decode(&route); err != nil {
+			shiftErrorOffset(err, start)
+			if delim == '[' {
+				return fmt.Errorf("route %d: %w", len(list)+1, err)
+			}
+			return fmt.Errorf("route '%s': %w", path, err)
+		}
+		if route.Path == "" {
+			route.Path = path
+		}
+		route.offset = start
+		list = append(list, route)
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	*rs = list
+	return nil
+}
+
+// Key returns the method and path identifying the route
+func (r Route) Key() string {
+	return strings.ToUpper(r.Method) + " " + r.DisplayPath()
+}
+
+// DisplayPath returns the path as shown to users. Regex routes are shown as
+// their expression prefixed with '~'.
+func (r Route) DisplayPath() string {
+	if r.Match != nil && r.Match.PathRegex != "" {
+		return "~" + r.Match.PathRegex
+	}
+	return r.Path
+}
+
+// identity returns the method, path, request matchers and required scenario
+// state of the route, which together must be unique within a config
+func (r Route) identity() string {
+	identity := r.Key()
+	if r.Match != nil {
+		// Maps are marshaled with sorted keys, so equal matchers give equal text
+		match, _ := json.Marshal(r.Match)
+		identity += " " + string(match)
+	}
+	if r.RequiredState != "" {
+		identity += " [" + r.Scenario + "=" + r.RequiredState + "]"
+	}
+	return identity
 }
 
 // Config represents the mock server configuration
 type Config struct {
-	Routes map[string]Route `json:"routes"`
+	// Include lists further config files, directories or glob patterns,
+	// relative to this config, that are loaded before it
+	Include []string `json:"include,omitempty"`
+
+	Routes Routes `json:"routes"`
+
+	// Resources maps collection names such as "users" to their seed items.
+	// Each collection is served as an in-memory CRUD resource.
+	Resources map[string][]interface{} `json:"resources,omitempty"`
+
+	// Defaults applies to every mock response
+	Defaults Defaults `json:"defaults,omitempty"`
+}
+
+// Defaults holds settings applied to every mock response
+type Defaults struct {
+	// Headers are added to every response; route headers take precedence
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // ValidationResult contains the results of config validation
 type ValidationResult struct {
-	ValidRoutes  map[string]Route
+	ValidRoutes  []Route
 	SkippedCount int
+
+	// Resources maps resource base paths such as "/users" to their seed items
+	Resources map[string][]map[string]interface{}
+
+	// DefaultHeaders are the valid default headers
+	DefaultHeaders map[string]string
+
+	// BodyFiles are the paths of the body files referenced by the routes
+	BodyFiles []string
+
+	// ConfigFiles are the resolved paths of the config files loaded, and
+	// ConfigDirs those of the directories loaded
+	ConfigFiles []string
+	ConfigDirs  []string
+
+	// Issues are the problems found, in config order
+	Issues []Issue
 }
 
-// LoadConfig loads and validates a configuration file
+// LoadConfig loads and validates a configuration file with its includes
 func LoadConfig(configFile string) (*ValidationResult, error) {
-	// Check if config file exists
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file '%s' not found", configFile)
+	return LoadConfigs([]string{configFile})
+}
+
+// validateRoutes validates and filters routes according to security rules.
+// Body files are loaded relative to the directory of each route's config.
+func validateRoutes(routes []Route) *ValidationResult {
+	var validRoutes []Route
+	var bodyFiles []string
+	var issues issueList
+	seen := make(map[string]int)
+	skippedCount := 0
+
+	for _, route := range routes {
+		paths, ok := checkRoute(&route, &issues)
+		bodyFiles = append(bodyFiles, paths...)
+		if !ok {
+			skippedCount++
+			continue
+		}
+
+		// The same method, path and matchers defined twice: the later
+		// definition wins, matching how the config behaved when routes were a
+		// plain map. Routes differing only in matchers are variants.
+		loc := routeLocation(route)
+		identity := route.identity()
+		if idx, ok := seen[identity]; ok {
+			if previous := validRoutes[idx].source; previous != route.source {
+				issues.warnf(loc, "Route '%s' overrides the definition in %s", route.Key(), previous)
+			} else {
+				issues.warnf(loc, "Duplicate route '%s', using the last definition", route.Key())
+			}
+			validRoutes[idx] = route
+			skippedCount++
+			continue
+		}
+
+		seen[identity] = len(validRoutes)
+		validRoutes = append(validRoutes, route)
 	}
 
-	// Resolve symlinks for security
-	resolvedConfigFile, err := filepath.EvalSymlinks(configFile)
-	if err != nil {
-		return nil, fmt.Errorf("error resolving config file path: %w", err)
+	return &ValidationResult{
+		ValidRoutes:  validRoutes,
+		SkippedCount: skippedCount,
+		BodyFiles:    bodyFiles,
+		Issues:       issues,
 	}
+}
 
-	// Read config file
-	data, err := os.ReadFile(resolvedConfigFile)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config file: %w", err)
+// ValidateRoute validates a route that doesn't come from a config file, such
+// as one added while the server runs, with the same checks as config routes.
+// Body files are refused as there is no config directory to resolve them
+// against. It returns the route with its corrections applied and the issues
+// found; the route is valid unless one of them is an error.
+func ValidateRoute(route Route) (Route, []Issue) {
+	var issues issueList
+
+	// Responses are corrected in place, so they must not be shared with the
+	// caller
+	route.Responses = slices.Clone(route.Responses)
+	if route.BodyFile != "" || slices.ContainsFunc(route.Responses, func(entry ResponseEntry) bool { return entry.BodyFile != "" }) {
+		issues.errorf(routeLocation(route), "bodyFile is only supported in config files for route '%s', skipping", route.DisplayPath())
+		route.BodyFile = ""
+		for i := range route.Responses {
+			route.Responses[i].BodyFile = ""
+		}
+	}
+
+	checkRoute(&route, &issues)
+	return route, issues
+}
+
+// checkRoute validates a route, correcting what can be corrected and loading
+// its body files. Every problem is recorded, not only the first, and it
+// reports whether the route is valid. It also returns the paths of the body
+// files referenced, so that they are watched even if they are invalid.
+func checkRoute(route *Route, issues *issueList) ([]string, bool) {
+	path := route.DisplayPath()
+	loc := routeLocation(*route)
+	ok := true
+	skip := func(format string, args ...interface{}) {
+		issues.errorf(loc, format, args...)
+		ok = false
+	}
+
+	// Validate the path pattern ({name} parameters and a trailing {name...}
+	// wildcard) unless the route matches its path by regex
+	if route.Match == nil || route.Match.PathRegex == "" {
+		if _, err := pathpattern.Parse(path); err != nil {
+			skip("Invalid path '%s': %v, skipping", path, err)
+		}
 	}
 
-	// Parse JSON config
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	// Validate request matchers
+	if err := validateMatch(route.Match); err != nil {
+		skip("Invalid matcher for route '%s': %v, skipping", path, err)
 	}
 
-	// Validate and filter routes
-	result := validateRoutes(config.Routes)
+	// Validate method
+	if isValidMethod(route.Method) {
+		route.Method = strings.ToUpper(route.Method)
+	} else {
+		skip("Unsupported method '%s' for route '%s', skipping", route.Method, path)
+	}
+
+	// Validate scenario states
+	if route.Scenario == "" && (route.RequiredState != "" || route.NewState != "") {
+		skip("requiredState/newState without scenario for route '%s', skipping", path)
+	}
+
+	// Validate response mode
+	switch strings.ToLower(route.ResponseMode) {
+	case "":
+		if len(route.Responses) > 0 {
+			route.ResponseMode = ModeSequence
+		}
+	case ModeSequence, ModeCycle, ModeRandom:
+		route.ResponseMode = strings.ToLower(route.ResponseMode)
+	default:
+		skip("Unsupported responseMode '%s' for route '%s', skipping", route.ResponseMode, path)
+	}
 
-	return result, nil
+	// Validate response bodies and load body files, then templates, which
+	// may come from body files
+	var bodyFiles []string
+	if err := validateBodies(*route); err != nil {
+		skip("Invalid body for route '%s': %v, skipping", path, err)
+	} else {
+		var err error
+		bodyFiles, err = loadBodyFiles(route, route.dir)
+		if err != nil {
+			skip("Invalid body for route '%s': %v, skipping", path, err)
+		} else if route.Template {
+			if err := validateTemplates(*route); err != nil {
+				skip("Invalid response template for route '%s': %v, skipping", path, err)
+			}
+		}
+	}
+
+	// Validate status code (must be valid HTTP status)
+	if route.Status != 0 && !isValidStatusCode(route.Status) {
+		issues.warnf(loc, "Invalid status code %d for route '%s', using default 200", route.Status, path)
+		route.Status = 200
+	}
+
+	// Validate and clamp delay (0 ≤ delay ≤ 30_000 ms)
+	route.Delay = clampDelay(route.Delay, path, loc, issues)
+
+	// Validate response headers
+	if err := validateHeaders(route.Headers); err != nil {
+		skip("Invalid header for route '%s': %v, skipping", path, err)
+	}
+	if err := validateEntryHeaders(route.Responses); err != nil {
+		skip("Invalid header for route '%s': %v, skipping", path, err)
+	}
+
+	// Validate each of several responses the same way
+	for i := range route.Responses {
+		entry := &route.Responses[i]
+		if entry.Status != 0 && !isValidStatusCode(entry.Status) {
+			issues.warnf(loc, "Invalid status code %d for response %d of route '%s', using the route status", entry.Status, i+1, path)
+			entry.Status = 0
+		}
+		entry.Delay = clampDelay(entry.Delay, path, loc, issues)
+	}
+
+	return bodyFiles, ok
 }
 
-// validateRoutes validates and filters routes according to security rules
-func validateRoutes(routes map[string]Route) *ValidationResult {
-	validRoutes := make(map[string]Route)
-	skippedCount := 0
+// validateResources validates resource collections and their seed items of
+// a config file. Collections are keyed by their base path.
+func validateResources(resources map[string][]interface{}, file string, issues *issueList) map[string][]map[string]interface{} {
+	valid := make(map[string][]map[string]interface{})
+	loc := location{file: file}
 
-	for path, route := range routes {
-		// Validate method
-		if !isValidMethod(route.Method) {
-			log.Printf("Warning: Unsupported method '%s' for route '%s', skipping", route.Method, path)
-			skippedCount++
+	// Names are sorted so that issues are reported in a stable order
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		items := resources[name]
+		base := "/" + strings.Trim(name, "/")
+		if base == "/" || strings.ContainsAny(base, "{}") {
+			issues.errorf(loc, "Invalid resource name '%s', skipping", name)
+			continue
+		}
+		if _, ok := valid[base]; ok {
+			issues.warnf(loc, "Duplicate resource '%s', skipping", base)
 			continue
 		}
 
-		// Validate status code (must be valid HTTP status)
-		if route.Status != 0 && !isValidStatusCode(route.Status) {
-			log.Printf("Warning: Invalid status code %d for route '%s', using default 200", route.Status, path)
-			route.Status = 200
+		seedItems := make([]map[string]interface{}, 0, len(items))
+		ids := make(map[string]bool)
+		for i, item := range items {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				issues.errorf(loc, "Item %d of resource '%s' is not an object, skipping", i+1, base)
+				continue
+			}
+			if id, ok := obj["id"]; ok {
+				key := fmt.Sprint(id)
+				if ids[key] {
+					issues.warnf(loc, "Duplicate id %s in resource '%s', skipping item", key, base)
+					continue
+				}
+				ids[key] = true
+			}
+			seedItems = append(seedItems, obj)
 		}
 
-		// Validate and clamp delay (0 ≤ delay ≤ 30_000 ms)
-		route.Delay = clampDelay(route.Delay, path)
+		valid[base] = seedItems
+	}
+
+	return valid
+}
 
-		validRoutes[path] = route
+// validateTemplates checks that every templated response of a route compiles
+func validateTemplates(route Route) error {
+	if _, err := render.Compile(route.Response); err != nil {
+		return err
+	}
+	if _, err := render.Compile(route.Body); err != nil {
+		return fmt.Errorf("body: %w", err)
 	}
+	for name, value := range route.Headers {
+		if _, err := render.Compile(value); err != nil {
+			return fmt.Errorf("header '%s': %w", name, err)
+		}
+	}
+	for i, entry := range route.Responses {
+		if _, err := render.Compile(entry.Response); err != nil {
+			return fmt.Errorf("response %d: %w", i+1, err)
+		}
+		if _, err := render.Compile(entry.Body); err != nil {
+			return fmt.Errorf("response %d body: %w", i+1, err)
+		}
+		for name, value := range entry.Headers {
+			if _, err := render.Compile(value); err != nil {
+				return fmt.Errorf("response %d header '%s': %w", i+1, name, err)
+			}
+		}
+	}
+	return nil
+}
 
-	return &ValidationResult{
-		ValidRoutes:  validRoutes,
-		SkippedCount: skippedCount,
+// validateBodies checks the bodies of a route and its response entries
+func validateBodies(route Route) error {
+	if err := validateBody(route.Response, route.Body, route.BodyBase64, route.BodyFile, route.ContentType); err != nil {
+		return err
+	}
+	for i, entry := range route.Responses {
+		if err := validateBody(entry.Response, entry.Body, entry.BodyBase64, entry.BodyFile, entry.ContentType); err != nil {
+			return fmt.Errorf("response %d: %w", i+1, err)
+		}
 	}
+	return nil
+}
+
+// validateBody checks that at most one kind of body is given, that a base64
+// body decodes and that the content type is a valid header value
+func validateBody(response interface{}, body, bodyBase64, bodyFile, contentType string) error {
+	kinds := 0
+	for _, set := range []bool{response != nil, body != "", bodyBase64 != "", bodyFile != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("only one of response, body, bodyBase64 and bodyFile may be set")
+	}
+
+	if bodyBase64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(bodyBase64); err != nil {
+			return fmt.Errorf("invalid bodyBase64: %w", err)
+		}
+	}
+
+	if contentType != "" {
+		if err := validateHeader("Content-Type", contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateDefaultHeaders returns the default headers, dropping invalid ones
+func validateDefaultHeaders(headers map[string]string, file string, issues *issueList) map[string]string {
+	valid := make(map[string]string, len(headers))
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		if err := validateHeader(name, headers[name]); err != nil {
+			issues.errorf(location{file: file}, "Invalid default header: %v, skipping", err)
+			continue
+		}
+		valid[name] = headers[name]
+	}
+	return valid
+}
+
+// validateEntryHeaders checks the headers of every response entry
+func validateEntryHeaders(entries []ResponseEntry) error {
+	for i, entry := range entries {
+		if err := validateHeaders(entry.Headers); err != nil {
+			return fmt.Errorf("response %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateHeaders checks the names and values of response headers
+func validateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if err := validateHeader(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateHeader checks that a header name is an HTTP token and that its
+// value has no control characters, which could split the response
+func validateHeader(name, value string) error {
+	if name == "" {
+		return fmt.Errorf("empty header name")
+	}
+	for _, c := range name {
+		if c > 0x7e || c <= 0x20 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", c) {
+			return fmt.Errorf("illegal character %q in header name '%s'", c, name)
+		}
+	}
+	for _, c := range value {
+		if (c < 0x20 && c != '\t') || c == 0x7f {
+			return fmt.Errorf("illegal character %q in value of header '%s'", c, name)
+		}
+	}
+	return nil
 }
 
 // isValidMethod checks if the HTTP method is allowed
@@ -109,14 +582,14 @@ func isValidStatusCode(status int) bool {
 }
 
 // clampDelay ensures delay is within safe bounds (0 ≤ delay ≤ 30_000 ms)
-func clampDelay(delay int, path string) int {
+func clampDelay(delay int, path string, loc location, issues *issueList) int {
 	if delay < 0 {
-		log.Printf("Warning: Negative delay %d for route '%s', using 0", delay, path)
+		issues.warnf(loc, "Negative delay %d for route '%s', using 0", delay, path)
 		return 0
 	}
 
 	if delay > 30000 {
-		log.Printf("Warning: Delay %dms exceeds 30s limit for route '%s', capping at 30s", delay, path)
+		issues.warnf(loc, "Delay %dms exceeds 30s limit for route '%s', capping at 30s", delay, path)
 		return 30000
 	}
 
@@ -126,6 +599,9 @@ func clampDelay(delay int, path string) int {
 // PrintRoutesTable prints a formatted table of routes
 func (vr *ValidationResult) PrintRoutesTable() {
 	fmt.Printf("Loaded %d mock routes", len(vr.ValidRoutes))
+	if len(vr.Resources) > 0 {
+		fmt.Printf(" and %d resources", len(vr.Resources))
+	}
 	if vr.SkippedCount > 0 {
 		fmt.Printf(" (skipped %d invalid routes)", vr.SkippedCount)
 	}
@@ -134,10 +610,13 @@ func (vr *ValidationResult) PrintRoutesTable() {
 	fmt.Println("│ METHOD │ PATH                     │ STATUS │ DELAY  │")
 	fmt.Println("├────────┼──────────────────────────┼────────┼────────┤")
 
-	// Print routes in table format
-	for path, route := range vr.ValidRoutes {
+	// Print routes in table format, in config order
+	for _, route := range vr.ValidRoutes {
 		method := strings.ToUpper(route.Method)
 		status := route.Status
+		if len(route.Responses) > 0 && route.Responses[0].Status != 0 {
+			status = route.Responses[0].Status
+		}
 		if status == 0 {
 			status = 200
 		}
@@ -150,12 +629,21 @@ func (vr *ValidationResult) PrintRoutesTable() {
 		}
 
 		// Truncate long paths
-		displayPath := path
+		displayPath := route.DisplayPath()
 		if len(displayPath) > 24 {
 			displayPath = displayPath[:21] + "..."
 		}
 
 		fmt.Printf("│ %-6s │ %-24s │ %-6d │ %-6s │\n", method, displayPath, status, delayStr)
 	}
+
+	// Print resources, each standing for its generated CRUD routes
+	for _, base := range slices.Sorted(maps.Keys(vr.Resources)) {
+		displayPath := base
+		if len(displayPath) > 24 {
+			displayPath = displayPath[:21] + "..."
+		}
+		fmt.Printf("│ %-6s │ %-24s │ %-6s │ %-6s │\n", "CRUD", displayPath, "-", "-")
+	}
 	fmt.Println("└────────┴──────────────────────────┴────────┴────────┘")
 }
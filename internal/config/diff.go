@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// RouteDiff lists the routes a reload added, removed and changed, each as
+// "METHOD path" with resources as "CRUD path"
+type RouteDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Empty reports whether a reload changed no routes
+func (d RouteDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffRoutes compares the routes and resources of two loaded configs. Routes
+// are told apart by their identity, as variants of one path may differ only
+// in matchers and scenario state.
+func DiffRoutes(old, new *ValidationResult) RouteDiff {
+	oldRoutes, oldOrder := routeDefinitions(old)
+	newRoutes, newOrder := routeDefinitions(new)
+
+	var diff RouteDiff
+	for _, id := range newOrder {
+		definition, existed := oldRoutes[id]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, newRoutes[id].label)
+		case definition.json != newRoutes[id].json:
+			diff.Changed = append(diff.Changed, newRoutes[id].label)
+		}
+	}
+	for _, id := range oldOrder {
+		if _, ok := newRoutes[id]; !ok {
+			diff.Removed = append(diff.Removed, oldRoutes[id].label)
+		}
+	}
+	return diff
+}
+
+// routeDefinition is a route or resource as compared by DiffRoutes
+type routeDefinition struct {
+	label string
+	json  string
+}
+
+// routeDefinitions returns the routes and resources of a config by identity,
+// and the identities in config order
+func routeDefinitions(vr *ValidationResult) (map[string]routeDefinition, []string) {
+	definitions := make(map[string]routeDefinition)
+	var order []string
+	add := func(id, label string, value interface{}) {
+		encoded, _ := json.Marshal(value)
+		order = append(order, id)
+		definitions[id] = routeDefinition{label: label, json: string(encoded)}
+	}
+
+	// Valid routes have unique identities
+	for _, route := range vr.ValidRoutes {
+		add(route.identity(), route.Key(), route)
+	}
+	for _, base := range slices.Sorted(maps.Keys(vr.Resources)) {
+		add("CRUD "+base, "CRUD "+base, vr.Resources[base])
+	}
+	return definitions, order
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Severity is the severity of a validation issue
+type Severity string
+
+const (
+	// SeverityError marks problems that make a route, resource or header be
+	// skipped
+	SeverityError Severity = "error"
+	// SeverityWarning marks problems that are corrected or overridden, such
+	// as a clamped delay or a route defined twice
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found while validating a config
+type Issue struct {
+	Severity Severity `json:"severity"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Route    string   `json:"route,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Location returns where the issue was found as "file:line", "file" or ""
+func (i Issue) Location() string {
+	switch {
+	case i.File == "":
+		return ""
+	case i.Line > 0:
+		return fmt.Sprintf("%s:%d", i.File, i.Line)
+	default:
+		return i.File
+	}
+}
+
+// String formats the issue with its location
+func (i Issue) String() string {
+	if loc := i.Location(); loc != "" {
+		return loc + ": " + i.Message
+	}
+	return i.Message
+}
+
+// ParseError is an error parsing a config file
+type ParseError struct {
+	File string
+	Line int // 0 if unknown
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("error parsing config file '%s': line %d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("error parsing config file '%s': %v", e.File, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// issueList collects validation issues
+type issueList []Issue
+
+// location is where an issue is found
+type location struct {
+	file  string
+	line  int
+	route string
+}
+
+// routeLocation returns the location of a route
+func routeLocation(route Route) location {
+	return location{file: route.source, line: route.line, route: route.Key()}
+}
+
+// errorf records an error
+func (l *issueList) errorf(loc location, format string, args ...interface{}) {
+	l.add(SeverityError, loc, format, args...)
+}
+
+// warnf records a warning
+func (l *issueList) warnf(loc location, format string, args ...interface{}) {
+	l.add(SeverityWarning, loc, format, args...)
+}
+
+func (l *issueList) add(severity Severity, loc location, format string, args ...interface{}) {
+	*l = append(*l, Issue{
+		Severity: severity,
+		File:     loc.file,
+		Line:     loc.line,
+		Route:    loc.route,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Count returns the number of errors and warnings
+func (vr *ValidationResult) Count() (errors, warnings int) {
+	for _, issue := range vr.Issues {
+		if issue.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// LogIssues logs the issues found, each prefixed with its severity as
+// reported by validate
+func (vr *ValidationResult) LogIssues() {
+	for _, issue := range vr.Issues {
+		log.Printf("%s: %s", issue.Severity, issue)
+	}
+}
+
+// splitErrorLine splits a "line N: " prefix, optionally after "yaml: ", off
+// an error message. It returns line 0 and the error as is if there is none.
+func splitErrorLine(err error) (int, error) {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	var line int
+	if _, scanErr := fmt.Sscanf(msg, "line %d:", &line); scanErr != nil {
+		return 0, err
+	}
+	_, rest, _ := strings.Cut(msg, ": ")
+	return line, errors.New(rest)
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LoadConfigs loads, merges and validates configuration files. Each path is
+// either a config file or a directory, whose .json, .yaml and .yml files are
+// loaded in name order.
+//
+// Files are merged in load order: the given paths in order, with a config's
+// includes loaded just before the config itself. Routes (same method, path
+// and matchers) and resources defined again in a later file override the
+// earlier definition, so a config can override what it includes.
+func LoadConfigs(paths []string) (*ValidationResult, error) {
+	l := &loader{
+		loading: make(map[string]bool),
+		loaded:  make(map[string]bool),
+	}
+	for _, path := range paths {
+		if err := l.loadPath(path, path, true); err != nil {
+			return nil, err
+		}
+	}
+	if len(l.configs) == 0 {
+		return nil, fmt.Errorf("no config files found in %s", strings.Join(paths, ", "))
+	}
+
+	// Merge routes in load order, remembering where each route comes from
+	var routes []Route
+	for _, lc := range l.configs {
+		for _, route := range lc.config.Routes {
+			route.source = lc.name
+			route.dir = lc.dir
+			routes = append(routes, route)
+		}
+	}
+
+	// Validate and filter routes, then merge resources and default headers
+	result := validateRoutes(routes)
+	result.Resources = make(map[string][]map[string]interface{})
+	result.DefaultHeaders = make(map[string]string)
+	issues := issueList(result.Issues)
+	resourceSources := make(map[string]string)
+	for _, lc := range l.configs {
+		resources := validateResources(lc.config.Resources, lc.name, &issues)
+		for _, base := range slices.Sorted(maps.Keys(resources)) {
+			if source, ok := resourceSources[base]; ok {
+				issues.warnf(location{file: lc.name}, "Resource '%s' overrides the definition in %s", base, source)
+			}
+			result.Resources[base] = resources[base]
+			resourceSources[base] = lc.name
+		}
+		for name, value := range validateDefaultHeaders(lc.config.Defaults.Headers, lc.name, &issues) {
+			result.DefaultHeaders[name] = value
+		}
+	}
+	result.Issues = issues
+	result.ConfigFiles = l.files
+	result.ConfigDirs = l.dirs
+
+	return result, nil
+}
+
+// loader loads config files and their includes
+type loader struct {
+	configs []loadedConfig
+	files   []string
+	dirs    []string
+
+	// loading holds the files being loaded to detect include cycles, loaded
+	// the files already loaded so that files included twice load once
+	loading map[string]bool
+	loaded  map[string]bool
+}
+
+// loadedConfig is a parsed config file
+type loadedConfig struct {
+	name   string // path for messages, as given or as included
+	dir    string // absolute directory for relative paths
+	config *Config
+}
+
+// loadPath loads a config file or the config files of a directory, named
+// name in messages. Files in a directory that are not config files are
+// ignored, as are hidden files.
+func (l *loader) loadPath(path, name string, required bool) error {
+	// Check if the path exists
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("config file '%s' not found", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if !info.IsDir() {
+		return l.loadFile(path, name)
+	}
+
+	// Resolve symlinks for security
+	resolvedDir, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("error resolving config directory path: %w", err)
+	}
+	if absDir, err := filepath.Abs(resolvedDir); err == nil {
+		l.dirs = append(l.dirs, absDir)
+	}
+
+	entries, err := os.ReadDir(resolvedDir)
+	if err != nil {
+		return fmt.Errorf("error reading config directory: %w", err)
+	}
+	found := false
+	for _, entry := range entries { // sorted by name
+		file := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(file, ".") || !IsConfigFile(file) {
+			continue
+		}
+		found = true
+		if err := l.loadFile(filepath.Join(resolvedDir, file), filepath.Join(name, file)); err != nil {
+			return err
+		}
+	}
+	if !found && required {
+		return fmt.Errorf("no config files found in directory '%s'", name)
+	}
+	return nil
+}
+
+// loadFile loads a config file, named configFile in messages, after the
+// files it includes
+func (l *loader) loadFile(path, configFile string) error {
+	// Resolve symlinks for security
+	resolvedConfigFile, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("error resolving config file path: %w", err)
+	}
+	absConfigFile, err := filepath.Abs(resolvedConfigFile)
+	if err != nil {
+		return fmt.Errorf("error resolving config file path: %w", err)
+	}
+	if l.loading[absConfigFile] {
+		return fmt.Errorf("config file '%s' is included in a cycle", configFile)
+	}
+	if l.loaded[absConfigFile] {
+		return nil
+	}
+
+	config, err := parseConfigFile(configFile, absConfigFile)
+	if err != nil {
+		return err
+	}
+
+	// Includes are relative to the config file and confined to its directory
+	// like body files
+	l.loading[absConfigFile] = true
+	dir := filepath.Dir(absConfigFile)
+	for _, include := range config.Include {
+		paths, err := includePaths(dir, include)
+		if err != nil {
+			return fmt.Errorf("error in config file '%s': %w", configFile, err)
+		}
+		for _, path := range paths {
+			name := filepath.Join(filepath.Dir(configFile), path)
+			if err := l.loadPath(filepath.Join(dir, path), name, false); err != nil {
+				return err
+			}
+		}
+	}
+	delete(l.loading, absConfigFile)
+
+	l.loaded[absConfigFile] = true
+	l.files = append(l.files, absConfigFile)
+	l.configs = append(l.configs, loadedConfig{name: configFile, dir: dir, config: config})
+	return nil
+}
+
+// includePaths returns the paths an include refers to, relative to dir. An
+// include is a file, a directory or a glob pattern.
+func includePaths(dir, include string) ([]string, error) {
+	if !filepath.IsLocal(include) {
+		return nil, fmt.Errorf("include '%s' must be a relative path inside the config directory", include)
+	}
+
+	paths := []string{include}
+	if strings.ContainsAny(include, "*?[") {
+		matches, err := filepath.Glob(filepath.Join(dir, include))
+		if err != nil {
+			return nil, fmt.Errorf("invalid include '%s': %w", include, err)
+		}
+		paths = paths[:0]
+		for _, match := range matches { // sorted by name
+			rel, err := filepath.Rel(dir, match)
+			if err != nil {
+				continue
+			}
+			if info, err := os.Stat(match); err == nil && !info.IsDir() && !IsConfigFile(match) {
+				continue
+			}
+			paths = append(paths, rel)
+		}
+	}
+
+	// Check after resolving symlinks that each path stays inside dir
+	for _, path := range paths {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(dir, path))
+		if err != nil {
+			return nil, fmt.Errorf("error resolving include '%s': %w", path, err)
+		}
+		if rel, err := filepath.Rel(dir, resolved); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("include '%s' resolves outside the config directory", path)
+		}
+	}
+	return paths, nil
+}
+
+// parseConfigFile reads and parses a single config file. YAML is converted to
+// JSON and decoded the same way. Routes remember the line they start on.
+func parseConfigFile(configFile, resolvedConfigFile string) (*Config, error) {
+	// Read config file
+	data, err := os.ReadFile(resolvedConfigFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	// Parse config, YAML is converted to JSON and decoded the same way
+	var lines *lineMap
+	if isYAMLFile(configFile) {
+		if data, lines, err = yamlToJSON(data); err != nil {
+			line, err := splitErrorLine(err)
+			return nil, &ParseError{File: configFile, Line: line, Err: err}
+		}
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, &ParseError{File: configFile, Line: jsonErrorLine(err, data, lines), Err: err}
+	}
+
+	base := routesOffset(data)
+	for i := range config.Routes {
+		config.Routes[i].line = offsetLine(data, lines, base+config.Routes[i].offset)
+	}
+	return &config, nil
+}
+
+// IsConfigFile reports whether a file is a config file by its extension
+func IsConfigFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".json") || isYAMLFile(name)
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/abdillahi-nur/mockr/internal/jsonpath"
+)
+
+// Match holds the request matchers of a route beyond its method and path
+type Match struct {
+	// PathRegex matches the request path against a regular expression instead
+	// of the route path, which then only serves as a label
+	PathRegex string `json:"pathRegex,omitempty"`
+
+	// Query matches query parameters by name
+	Query map[string]ValueMatcher `json:"query,omitempty"`
+
+	// Headers matches request headers by case-insensitive name
+	Headers map[string]ValueMatcher `json:"headers,omitempty"`
+
+	// Cookies matches request cookies by name
+	Cookies map[string]ValueMatcher `json:"cookies,omitempty"`
+
+	// Body matches the request body
+	Body *BodyMatcher `json:"body,omitempty"`
+}
+
+// BodyMatcher matches the request body. All conditions that are set must
+// hold.
+type BodyMatcher struct {
+	// EqualsJSON requires the body to be JSON equal to the given value
+	EqualsJSON interface{} `json:"equalsJson,omitempty"`
+
+	// ContainsJSON requires the body to be JSON containing the given value:
+	// objects may have extra members and arrays extra elements
+	ContainsJSON interface{} `json:"containsJson,omitempty"`
+
+	// JSONPath matches the values selected by JSONPath expressions such as
+	// $.user.name; strings are compared as is, other values as JSON text
+	JSONPath map[string]ValueMatcher `json:"jsonPath,omitempty"`
+
+	// Regex matches the raw body text
+	Regex string `json:"regex,omitempty"`
+}
+
+// ValueMatcher matches the values of a named request attribute such as a
+// query parameter or header. All conditions that are set must hold; with
+// none set the attribute only has to be present.
+type ValueMatcher struct {
+	Equals  *string `json:"equals,omitempty"`
+	Present *bool   `json:"present,omitempty"`
+	Regex   string  `json:"regex,omitempty"`
+}
+
+// UnmarshalJSON accepts a string, number or boolean as shorthand for
+// {"equals": "..."}. Numbers and booleans are matched by their literal text,
+// so that page: 2 matches ?page=2.
+func (m *ValueMatcher) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' && data[0] != '[' && !bytes.Equal(data, []byte("null")) {
+		var scalar interface{}
+		if err := json.Unmarshal(data, &scalar); err != nil {
+			return err
+		}
+		equals := string(data)
+		if s, ok := scalar.(string); ok {
+			equals = s
+		}
+		*m = ValueMatcher{Equals: &equals}
+		return nil
+	}
+
+	type plain ValueMatcher
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("invalid value matcher %s: must be a string, number, boolean or an object with equals, present or regex", data)
+	}
+	*m = ValueMatcher(p)
+	return nil
+}
+
+// validateMatch checks that all matcher expressions compile
+func validateMatch(m *Match) error {
+	if m == nil {
+		return nil
+	}
+
+	if m.PathRegex != "" {
+		if _, err := regexp.Compile(m.PathRegex); err != nil {
+			return fmt.Errorf("invalid pathRegex '%s': %w", m.PathRegex, err)
+		}
+	}
+
+	for name, vm := range m.Query {
+		if err := validateValueMatcher(vm); err != nil {
+			return fmt.Errorf("query parameter '%s': %w", name, err)
+		}
+	}
+
+	for name, vm := range m.Headers {
+		if err := validateValueMatcher(vm); err != nil {
+			return fmt.Errorf("header '%s': %w", name, err)
+		}
+	}
+
+	for name, vm := range m.Cookies {
+		if err := validateValueMatcher(vm); err != nil {
+			return fmt.Errorf("cookie '%s': %w", name, err)
+		}
+	}
+
+	if b := m.Body; b != nil {
+		for expr, vm := range b.JSONPath {
+			if _, err := jsonpath.Parse(expr); err != nil {
+				return fmt.Errorf("body jsonPath '%s': %w", expr, err)
+			}
+			if err := validateValueMatcher(vm); err != nil {
+				return fmt.Errorf("body jsonPath '%s': %w", expr, err)
+			}
+		}
+		if b.Regex != "" {
+			if _, err := regexp.Compile(b.Regex); err != nil {
+				return fmt.Errorf("invalid body regex '%s': %w", b.Regex, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateValueMatcher checks a single value matcher
+func validateValueMatcher(vm ValueMatcher) error {
+	if vm.Regex != "" {
+		if _, err := regexp.Compile(vm.Regex); err != nil {
+			return fmt.Errorf("invalid regex '%s': %w", vm.Regex, err)
+		}
+	}
+	if vm.Present != nil && !*vm.Present && (vm.Equals != nil || vm.Regex != "") {
+		return fmt.Errorf("'present: false' cannot be combined with equals or regex")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValueMatcherUnmarshalJSON(t *testing.T) {
+	str := func(s string) *string { return &s }
+	yes := true
+
+	tests := []struct {
+		json    string
+		want    ValueMatcher
+		wantErr string
+	}{
+		{json: `"admin"`, want: ValueMatcher{Equals: str("admin")}},
+		{json: `""`, want: ValueMatcher{Equals: str("")}},
+		{json: `2`, want: ValueMatcher{Equals: str("2")}},
+		{json: `1.50`, want: ValueMatcher{Equals: str("1.50")}},
+		{json: `-1e3`, want: ValueMatcher{Equals: str("-1e3")}},
+		{json: `true`, want: ValueMatcher{Equals: str("true")}},
+		{json: `false`, want: ValueMatcher{Equals: str("false")}},
+		{json: `{"equals": "x"}`, want: ValueMatcher{Equals: str("x")}},
+		{json: `{"present": true}`, want: ValueMatcher{Present: &yes}},
+		{json: `{"regex": "^a"}`, want: ValueMatcher{Regex: "^a"}},
+		{json: `{}`, want: ValueMatcher{}},
+		{json: `null`, want: ValueMatcher{}},
+		{json: `[1]`, wantErr: "invalid value matcher [1]"},
+		{json: `{"equals": 2}`, wantErr: "invalid value matcher"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var m ValueMatcher
+			err := json.Unmarshal([]byte(tt.json), &m)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() failed: %v", err)
+			}
+			if describe(m) != describe(tt.want) {
+				t.Errorf("matcher = %s, want %s", describe(m), describe(tt.want))
+			}
+		})
+	}
+}
+
+// describe formats a matcher as JSON for comparison and messages
+func describe(m ValueMatcher) string {
+	data, _ := json.Marshal(m)
+	return string(data)
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxYAMLNodes limits the number of nodes a YAML config expands to, so that
+// nested aliases cannot blow up the config
+const maxYAMLNodes = 1_000_000
+
+// isYAMLFile reports whether a config file is YAML by its extension
+func isYAMLFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// yamlToJSON converts a YAML document to JSON so that it is decoded exactly
+// like a JSON config. Anchors, aliases and merge keys are expanded and
+// mapping keys keep their order, including duplicate keys. The returned
+// lines map JSON offsets back to YAML lines for error messages.
+func yamlToJSON(data []byte) ([]byte, *lineMap, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	c := &yamlConverter{lines: &lineMap{}}
+	if len(doc.Content) == 0 {
+		// Empty document
+		c.buf.WriteString("{}")
+		return c.buf.Bytes(), c.lines, nil
+	}
+	if err := c.write(doc.Content[0], doc.Content[0].Line); err != nil {
+		return nil, nil, err
+	}
+	return c.buf.Bytes(), c.lines, nil
+}
+
+// yamlConverter writes YAML nodes as JSON
+type yamlConverter struct {
+	buf   bytes.Buffer
+	lines *lineMap
+	nodes int
+}
+
+// write writes a node as JSON. line is the line the node is reported on,
+// which for mapping values is the line of their key.
+func (c *yamlConverter) write(n *yaml.Node, line int) error {
+	c.nodes++
+	if c.nodes > maxYAMLNodes {
+		return fmt.Errorf("line %d: document expands to more than %d nodes", n.Line, maxYAMLNodes)
+	}
+	c.lines.add(c.buf.Len(), line)
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		return c.write(n.Alias, line)
+	case yaml.DocumentNode:
+		return c.write(n.Content[0], n.Content[0].Line)
+	case yaml.SequenceNode:
+		c.buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			if err := c.write(item, item.Line); err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(n, 0)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteByte('{')
+		for i, pair := range pairs {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(pair[0].Value)
+			c.buf.Write(key)
+			c.buf.WriteByte(':')
+			if err := c.write(pair[1], pair[0].Line); err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte('}')
+		return nil
+	default:
+		var value interface{}
+		if err := n.Decode(&value); err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		c.buf.Write(encoded)
+		return nil
+	}
+}
+
+// mappingPairs returns the key and value nodes of a mapping with merge keys
+// ("<<") expanded. Keys of the mapping itself override merged keys, and
+// earlier merged mappings override later ones.
+func mappingPairs(n *yaml.Node, depth int) ([][2]*yaml.Node, error) {
+	if depth > 100 {
+		return nil, fmt.Errorf("line %d: merge keys nested too deeply", n.Line)
+	}
+
+	var own, merged [][2]*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+		if key.Tag != "!!merge" {
+			own = append(own, [2]*yaml.Node{key, value})
+			continue
+		}
+
+		sources := []*yaml.Node{value}
+		if resolveAlias(value).Kind == yaml.SequenceNode {
+			sources = resolveAlias(value).Content
+		}
+		for _, source := range sources {
+			source = resolveAlias(source)
+			if source.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("line %d: merge key requires a mapping or a list of mappings", source.Line)
+			}
+			pairs, err := mappingPairs(source, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			merged = append(merged, pairs...)
+		}
+	}
+
+	// Drop merged keys that are overridden
+	defined := make(map[string]bool, len(own))
+	for _, pair := range own {
+		defined[pair[0].Value] = true
+	}
+	var pairs [][2]*yaml.Node
+	for _, pair := range merged {
+		if defined[pair[0].Value] {
+			continue
+		}
+		defined[pair[0].Value] = true
+		pairs = append(pairs, pair)
+	}
+	return append(pairs, own...), nil
+}
+
+// resolveAlias follows alias nodes to the node they refer to
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// lineMap maps offsets in generated JSON to lines of the source document
+type lineMap struct {
+	offsets []int
+	lines   []int
+}
+
+// add records that the JSON at offset comes from line
+func (m *lineMap) add(offset, line int) {
+	m.offsets = append(m.offsets, offset)
+	m.lines = append(m.lines, line)
+}
+
+// line returns the source line of the last JSON value starting at or before
+// offset
+func (m *lineMap) line(offset int) int {
+	i := sort.Search(len(m.offsets), func(i int) bool { return m.offsets[i] > offset })
+	if i == 0 {
+		return 0
+	}
+	return m.lines[i-1]
+}
+
+// jsonErrorLine returns the line an error from decoding JSON refers to, or 0
+// if the error carries no position
+func jsonErrorLine(err error, data []byte, lines *lineMap) int {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return 0
+	}
+
+	// Offsets of route errors are relative to the routes value
+	var routesErr *routesError
+	if errors.As(err, &routesErr) {
+		offset += routesOffset(data)
+	}
+	return offsetLine(data, lines, offset)
+}
+
+// offsetLine returns the line of an offset in JSON data. The line is looked
+// up in lines for converted YAML, and counted in data for JSON.
+func offsetLine(data []byte, lines *lineMap, offset int64) int {
+	if lines != nil {
+		return lines.line(int(offset))
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// routesError is an error decoding the routes of a config
+type routesError struct {
+	err error
+}
+
+func (e *routesError) Error() string { return e.err.Error() }
+func (e *routesError) Unwrap() error { return e.err }
+
+// routesOffset returns the offset of the top-level "routes" value in a JSON
+// document, or 0 if there is none
+func routesOffset(data []byte) int64 {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return 0
+		}
+		if key == "routes" {
+			return valueOffset(data, dec.InputOffset())
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return 0
+		}
+	}
+	return 0
+}
+
+// valueOffset returns the offset of the value following an object key or
+// array element that ends at offset, skipping separators and whitespace
+func valueOffset(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n:,", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// shiftErrorOffset adds by to the offset of a JSON decoding error, for errors
+// from decoding part of a document
+func shiftErrorOffset(err error, by int64) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		syntaxErr.Offset += by
+	case errors.As(err, &typeErr):
+		typeErr.Offset += by
+	}
+}
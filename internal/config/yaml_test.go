@@ -0,0 +1,139 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "empty document",
+			yaml: "",
+			want: `{}`,
+		},
+		{
+			name: "scalars",
+			yaml: "a: 1\nb: true\nc: x\nd: null\ne: 1.5\nf: \"007\"",
+			want: `{"a":1,"b":true,"c":"x","d":null,"e":1.5,"f":"007"}`,
+		},
+		{
+			name: "key order and duplicate keys",
+			yaml: "b: 1\na: 2\nb: 3",
+			want: `{"b":1,"a":2,"b":3}`,
+		},
+		{
+			name: "sequences and flow style",
+			yaml: "items:\n  - 1\n  - {id: 2}\n  - [3]",
+			want: `{"items":[1,{"id":2},[3]]}`,
+		},
+		{
+			name: "alias",
+			yaml: "a: &a [1, 2]\nb: *a",
+			want: `{"a":[1,2],"b":[1,2]}`,
+		},
+		{
+			name: "merge key",
+			yaml: "base: &b\n  x: 1\n  y: 2\nr:\n  <<: *b\n  y: 3",
+			want: `{"base":{"x":1,"y":2},"r":{"x":1,"y":3}}`,
+		},
+		{
+			name: "merge key with own key first",
+			yaml: "base: &b\n  x: 1\nr:\n  z: 0\n  <<: *b",
+			want: `{"base":{"x":1},"r":{"x":1,"z":0}}`,
+		},
+		{
+			name: "merge key list, earlier mappings win",
+			yaml: "a: &a {x: 1}\nb: &b {x: 2, y: 2}\nc:\n  <<: [*a, *b]",
+			want: `{"a":{"x":1},"b":{"x":2,"y":2},"c":{"x":1,"y":2}}`,
+		},
+		{
+			name: "nested merge keys",
+			yaml: "a: &a {x: 1}\nb: &b {<<: *a, y: 2}\nc: {<<: *b}",
+			want: `{"a":{"x":1},"b":{"x":1,"y":2},"c":{"x":1,"y":2}}`,
+		},
+		{
+			name:    "merge key of a scalar",
+			yaml:    "a: &a 1\nb:\n  <<: *a",
+			wantErr: true,
+		},
+		{
+			name:    "non-scalar key",
+			yaml:    "? [a]\n: 1",
+			wantErr: true,
+		},
+		{
+			name:    "syntax error",
+			yaml:    "a: [1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := yamlToJSON([]byte(tt.yaml))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("yamlToJSON() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("yamlToJSON() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("yamlToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONLines(t *testing.T) {
+	const doc = `base: &b
+  status: 201
+routes:
+  /a:
+    <<: *b
+    method: GET
+  /b:
+    method: POST
+`
+	data, lines, err := yamlToJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("yamlToJSON() failed: %v", err)
+	}
+	const want = `{"base":{"status":201},"routes":{"/a":{"status":201,"method":"GET"},"/b":{"method":"POST"}}}`
+	if string(data) != want {
+		t.Fatalf("yamlToJSON() = %s, want %s", data, want)
+	}
+
+	// Values are reported on the line of their key, and merged values on the
+	// line they are defined on
+	tests := []struct {
+		value string
+		last  bool // look up the last occurrence of value
+		line  int
+	}{
+		{value: `{"base"`, line: 1},
+		{value: `201`, line: 2},
+		{value: `201`, last: true, line: 2},
+		{value: `{"/a"`, line: 3},
+		{value: `{"status":201,"method"`, line: 4},
+		{value: `"GET"`, line: 6},
+		{value: `{"method":"POST"`, line: 7},
+		{value: `"POST"`, line: 8},
+	}
+	for _, tt := range tests {
+		offset := strings.Index(string(data), tt.value)
+		if tt.last {
+			offset = strings.LastIndex(string(data), tt.value)
+		}
+		if got := lines.line(offset); got != tt.line {
+			t.Errorf("line of %s at offset %d = %d, want %d", tt.value, offset, got, tt.line)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package jsonpath evaluates a subset of JSONPath expressions against decoded
+// JSON values. Supported syntax: the root $, child access with .name or
+// ['name'], array indexes [n] (negative counts from the end), and the
+// wildcards .* and [*].
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is a single selector of a path
+type step struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	raw   string
+	steps []step
+}
+
+// Parse compiles a JSONPath expression
+func Parse(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("expression must start with '$'")
+	}
+
+	p := &Path{raw: expr}
+	rest := expr[1:]
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".*"):
+			p.steps = append(p.steps, step{wildcard: true})
+			rest = rest[2:]
+
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("empty member name in '%s'", expr)
+			}
+			p.steps = append(p.steps, step{name: name})
+			rest = rest[end+1:]
+
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '[' in '%s'", expr)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			switch {
+			case selector == "*":
+				p.steps = append(p.steps, step{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				p.steps = append(p.steps, step{name: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid selector '[%s]' in '%s'", selector, expr)
+				}
+				p.steps = append(p.steps, step{index: index, isIndex: true})
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in '%s'", rest[0], expr)
+		}
+	}
+
+	return p, nil
+}
+
+// String returns the expression as written
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Eval returns every value selected by the path. Missing members and out of
+// range indexes select nothing.
+func (p *Path) Eval(doc interface{}) []interface{} {
+	current := []interface{}{doc}
+
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, value := range current {
+			switch v := value.(type) {
+			case map[string]interface{}:
+				if st.wildcard {
+					for _, child := range v {
+						next = append(next, child)
+					}
+				} else if child, ok := v[st.name]; ok && !st.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				switch {
+				case st.wildcard:
+					next = append(next, v...)
+				case st.isIndex:
+					index := st.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		current = next
+	}
+
+	return current
+}
@@ -0,0 +1,106 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "$"},
+		{expr: "$.name"},
+		{expr: "$.user.name"},
+		{expr: "$['user']['first name']"},
+		{expr: `$["user"]`},
+		{expr: "$.items[0].id"},
+		{expr: "$.items[-1]"},
+		{expr: "$.items[*].id"},
+		{expr: "$.*"},
+		{expr: "$.items[ 0 ]"},
+		{expr: "", wantErr: true},
+		{expr: "name", wantErr: true},
+		{expr: "$.", wantErr: true},
+		{expr: "$..name", wantErr: true},
+		{expr: "$.items[0", wantErr: true},
+		{expr: "$.items[a]", wantErr: true},
+		{expr: "$.items['a\"]", wantErr: true},
+		{expr: "$name", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Parse(tt.expr)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want an error", tt.expr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := p.String(); got != tt.expr {
+				t.Errorf("String() = %q, want %q", got, tt.expr)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	const doc = `{
+		"name": "Ada",
+		"user": {"first name": "Ada", "age": 36, "admin": true, "manager": null},
+		"items": [{"id": 1, "tags": ["a", "b"]}, {"id": 2, "tags": []}, {"id": 3}],
+		"one": {"only": "x"}
+	}`
+	var value interface{}
+	if err := json.Unmarshal([]byte(doc), &value); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want []interface{}
+	}{
+		{expr: "$.name", want: []interface{}{"Ada"}},
+		{expr: "$.user['first name']", want: []interface{}{"Ada"}},
+		{expr: "$.user.age", want: []interface{}{36.0}},
+		{expr: "$.user.admin", want: []interface{}{true}},
+		{expr: "$.user.manager", want: []interface{}{nil}},
+		{expr: "$.items[0].id", want: []interface{}{1.0}},
+		{expr: "$.items[-1].id", want: []interface{}{3.0}},
+		{expr: "$.items[*].id", want: []interface{}{1.0, 2.0, 3.0}},
+		{expr: "$.items[0].tags[*]", want: []interface{}{"a", "b"}},
+		{expr: "$.items[*].tags[0]", want: []interface{}{"a"}},
+		{expr: "$.one.*", want: []interface{}{"x"}},
+		{expr: "$.missing", want: nil},
+		{expr: "$.name.first", want: nil},
+		{expr: "$.items[3]", want: nil},
+		{expr: "$.items[-4]", want: nil},
+		{expr: "$.items.id", want: nil},
+		{expr: "$.user[0]", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := p.Eval(value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Eval() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	// The root selects the whole document
+	p, err := Parse("$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Eval(value); len(got) != 1 || !reflect.DeepEqual(got[0], value) {
+		t.Errorf("Eval() of $ = %#v, want the document", got)
+	}
+}
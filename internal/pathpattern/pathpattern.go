@@ -0,0 +1,171 @@
+// Package pathpattern parses and matches route path patterns such as
+// /api/users/{id} and /files/{path...}.
+package pathpattern
+
+import (
+	"fmt"
+	"strings"
+)
+
+// segment kinds, ordered from most to least specific
+const (
+	kindLiteral = iota
+	kindParam
+	kindWildcard
+)
+
+// segment is a single slash-separated part of a pattern
+type segment struct {
+	kind  int
+	value string // literal text or parameter name
+}
+
+// Pattern is a compiled route path
+type Pattern struct {
+	raw      string
+	segments []segment
+}
+
+// Parse compiles a path pattern. Segments written as {name} capture a single
+// path segment; a final {name...} segment captures the rest of the path.
+func Parse(path string) (*Pattern, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("path must start with '/'")
+	}
+
+	parts := strings.Split(path[1:], "/")
+	segments := make([]segment, 0, len(parts))
+	names := make(map[string]bool)
+
+	for i, part := range parts {
+		if !strings.ContainsAny(part, "{}") {
+			segments = append(segments, segment{kind: kindLiteral, value: part})
+			continue
+		}
+
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("parameter segment '%s' must be a whole segment like {name}", part)
+		}
+
+		name := part[1 : len(part)-1]
+		kind := kindParam
+		if strings.HasSuffix(name, "...") {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("wildcard segment '%s' must be the last segment", part)
+			}
+			name = strings.TrimSuffix(name, "...")
+			kind = kindWildcard
+		}
+
+		if !isValidName(name) {
+			return nil, fmt.Errorf("invalid parameter name '%s'", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate parameter name '%s'", name)
+		}
+		names[name] = true
+
+		segments = append(segments, segment{kind: kind, value: name})
+	}
+
+	return &Pattern{raw: path, segments: segments}, nil
+}
+
+// isValidName checks that a parameter name is a Go-style identifier
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the pattern as written in the config
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Names returns the parameter names captured by the pattern, in order
+func (p *Pattern) Names() []string {
+	var names []string
+	for _, seg := range p.segments {
+		if seg.kind != kindLiteral {
+			names = append(names, seg.value)
+		}
+	}
+	return names
+}
+
+// Match reports whether the request path matches the pattern and returns the
+// captured parameters
+func (p *Pattern) Match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+
+	rest := path[1:]
+	var params map[string]string
+
+	for i, seg := range p.segments {
+		if seg.kind == kindWildcard {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.value] = rest
+			return params, true
+		}
+
+		part := rest
+		next := ""
+		last := true
+		if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+			part = rest[:idx]
+			next = rest[idx+1:]
+			last = false
+		}
+
+		// Every segment but the last must be followed by a slash and vice versa
+		if last != (i == len(p.segments)-1) {
+			return nil, false
+		}
+
+		switch seg.kind {
+		case kindLiteral:
+			if part != seg.value {
+				return nil, false
+			}
+		case kindParam:
+			if part == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.value] = part
+		}
+
+		rest = next
+	}
+
+	return params, true
+}
+
+// Compare orders patterns by specificity. It returns a negative number when a
+// is more specific than b, a positive number when b is more specific, and 0
+// when neither is. Segments are compared left to right: literals beat
+// parameters, which beat wildcards.
+func Compare(a, b *Pattern) int {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if diff := a.segments[i].kind - b.segments[i].kind; diff != 0 {
+			return diff
+		}
+	}
+	return len(b.segments) - len(a.segments)
+}
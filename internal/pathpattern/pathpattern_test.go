@@ -0,0 +1,137 @@
+package pathpattern
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path    string
+		names   []string
+		wantErr bool
+	}{
+		{path: "/"},
+		{path: "/users"},
+		{path: "/users/{id}", names: []string{"id"}},
+		{path: "/users/{id}/posts/{post_id}", names: []string{"id", "post_id"}},
+		{path: "/files/{path...}", names: []string{"path"}},
+		{path: "/users/", names: nil},
+		{path: "users", wantErr: true},
+		{path: "", wantErr: true},
+		{path: "/users/id{id}", wantErr: true},
+		{path: "/users/{id", wantErr: true},
+		{path: "/users/{}", wantErr: true},
+		{path: "/users/{1id}", wantErr: true},
+		{path: "/users/{user-id}", wantErr: true},
+		{path: "/users/{id}/{id}", wantErr: true},
+		{path: "/files/{path...}/raw", wantErr: true},
+		{path: "/files/{...}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := Parse(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) succeeded, want an error", tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.path, err)
+			}
+			if got := p.String(); got != tt.path {
+				t.Errorf("String() = %q, want %q", got, tt.path)
+			}
+			if got := p.Names(); !slices.Equal(got, tt.names) {
+				t.Errorf("Names() = %q, want %q", got, tt.names)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		params  map[string]string
+		ok      bool
+	}{
+		{pattern: "/", path: "/", ok: true},
+		{pattern: "/", path: "/users", ok: false},
+		{pattern: "/users", path: "/users", ok: true},
+		{pattern: "/users", path: "/users/", ok: false},
+		{pattern: "/users", path: "/user", ok: false},
+		{pattern: "/users", path: "users", ok: false},
+		{pattern: "/users/", path: "/users/", ok: true},
+		{pattern: "/users/", path: "/users", ok: false},
+		{pattern: "/users/{id}", path: "/users/42", params: map[string]string{"id": "42"}, ok: true},
+		{pattern: "/users/{id}", path: "/users/", ok: false},
+		{pattern: "/users/{id}", path: "/users", ok: false},
+		{pattern: "/users/{id}", path: "/users/42/posts", ok: false},
+		{pattern: "/users/{id}/posts/{post}", path: "/users/1/posts/2", params: map[string]string{"id": "1", "post": "2"}, ok: true},
+		{pattern: "/files/{path...}", path: "/files/a/b/c.txt", params: map[string]string{"path": "a/b/c.txt"}, ok: true},
+		{pattern: "/files/{path...}", path: "/files/", params: map[string]string{"path": ""}, ok: true},
+		{pattern: "/files/{path...}", path: "/files", ok: false},
+		{pattern: "/files/{path...}", path: "/other/a", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			p, err := Parse(tt.pattern)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.pattern, err)
+			}
+			params, ok := p.Match(tt.path)
+			if ok != tt.ok {
+				t.Fatalf("Match(%q) ok = %v, want %v", tt.path, ok, tt.ok)
+			}
+			if ok && !maps.Equal(params, tt.params) {
+				t.Errorf("Match(%q) params = %v, want %v", tt.path, params, tt.params)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign of Compare(a, b)
+	}{
+		{a: "/users/me", b: "/users/{id}", want: -1},
+		{a: "/users/{id}", b: "/users/{path...}", want: -1},
+		{a: "/users/me", b: "/users/{path...}", want: -1},
+		{a: "/users/{id}", b: "/users/me", want: 1},
+		{a: "/users/{id}", b: "/users/{name}", want: 0},
+		{a: "/users", b: "/posts", want: 0},
+		{a: "/users/{id}/posts", b: "/users/{id}", want: -1},
+		{a: "/{a}/me", b: "/users/{id}", want: 1},
+		{a: "/files/{path...}", b: "/files", want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" "+tt.b, func(t *testing.T) {
+			a, err := Parse(tt.a)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.a, err)
+			}
+			b, err := Parse(tt.b)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.b, err)
+			}
+			if got := sign(Compare(a, b)); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want sign %d", tt.a, tt.b, Compare(a, b), tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
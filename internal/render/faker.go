@@ -0,0 +1,180 @@
+package render
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// fakeMu guards fakeRand, which is shared by all requests
+	fakeMu   sync.Mutex
+	fakeRand = rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))
+
+	// fakeEpoch anchors generated dates so seeded runs are reproducible
+	fakeEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Seed makes fake data, uuids and random responses reproducible across runs
+func Seed(seed uint64) {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	fakeRand = rand.New(rand.NewPCG(seed, 0))
+}
+
+// randIntN returns a random int in [0, n)
+func randIntN(n int) int {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return fakeRand.IntN(n)
+}
+
+// RandIntN returns a random int in [0, n) from the fake data source, so that
+// other random choices are reproducible with Seed too
+func RandIntN(n int) int {
+	return randIntN(n)
+}
+
+// randFloat returns a random float64 in [0, 1)
+func randFloat() float64 {
+	fakeMu.Lock()
+	defer fakeMu.Unlock()
+	return fakeRand.Float64()
+}
+
+// pick returns a random element of a list
+func pick(list []string) string {
+	return list[randIntN(len(list))]
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Farah", "George", "Hana", "Ivan", "Julia", "Kofi", "Lena", "Mohamed", "Nora", "Omar", "Priya", "Quinn", "Rosa", "Sam", "Tariq", "Uma", "Victor", "Wei", "Yara", "Zane"}
+	lastNames  = []string{"Smith", "Johnson", "Garcia", "Nguyen", "Ali", "Kim", "Müller", "Rossi", "Silva", "Okafor", "Patel", "Cohen", "Novak", "Hansen", "Dubois", "Tanaka", "Ivanova", "Lopez", "Brown", "Ahmed"}
+	domains    = []string{"example.com", "example.org", "example.net", "mail.test", "demo.dev"}
+	streets    = []string{"Main St", "Oak Ave", "Maple Rd", "Cedar Ln", "Park Blvd", "Lake Dr", "Hill St", "River Rd", "Elm St", "Sunset Ave"}
+	cities     = []string{"Springfield", "Riverside", "Fairview", "Madison", "Georgetown", "Franklin", "Clinton", "Salem", "Ashland", "Bristol"}
+	countries  = []string{"United States", "Canada", "United Kingdom", "Germany", "France", "Kenya", "Japan", "Brazil", "India", "Australia"}
+	companies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Stark", "Wayne", "Wonka", "Cyberdyne", "Soylent"}
+	suffixes   = []string{"Inc", "LLC", "Group", "Labs", "Systems", "Corp"}
+	loremWords = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris nisi aliquip ex ea commodo consequat")
+)
+
+// fakeFuncs are the fake data helpers available to templates
+var fakeFuncs = map[string]interface{}{
+	"fakeFirstName": func() string { return pick(firstNames) },
+	"fakeLastName":  func() string { return pick(lastNames) },
+	"fakeName":      fakeName,
+	"fakeEmail":     fakeEmail,
+	"fakeUsername":  fakeUsername,
+	"fakePhone":     fakePhone,
+	"fakeStreet":    fakeStreet,
+	"fakeCity":      func() string { return pick(cities) },
+	"fakeCountry":   func() string { return pick(countries) },
+	"fakeZip":       func() string { return fmt.Sprintf("%05d", randIntN(100000)) },
+	"fakeAddress":   fakeAddress,
+	"fakeCompany":   func() string { return pick(companies) + " " + pick(suffixes) },
+	"fakeDate":      func() string { return fakeTime().Format("2006-01-02") },
+	"fakeTimestamp": func() string { return fakeTime().Format(time.RFC3339) },
+	"fakeWord":      func() string { return pick(loremWords) },
+	"fakeWords":     fakeWords,
+	"fakeSentence":  fakeSentence,
+	"fakeParagraph": fakeParagraph,
+	"fakeInt":       fakeInt,
+	"fakeFloat":     fakeFloat,
+	"fakeBool":      func() bool { return randIntN(2) == 1 },
+	"fakePick":      func(options ...string) string { return pick(options) },
+}
+
+// fakeName returns a random full name
+func fakeName() string {
+	return pick(firstNames) + " " + pick(lastNames)
+}
+
+// fakeUsername returns a random lower-case username
+func fakeUsername() string {
+	return strings.ToLower(pick(firstNames)) + fmt.Sprintf("%d", randIntN(1000))
+}
+
+// fakeEmail returns a random email address
+func fakeEmail() string {
+	return fmt.Sprintf("%s.%s@%s", strings.ToLower(pick(firstNames)), strings.ToLower(pick(lastNames)), pick(domains))
+}
+
+// fakePhone returns a random phone number in a reserved fictional range
+func fakePhone() string {
+	return fmt.Sprintf("+1-555-%03d-%04d", randIntN(1000), randIntN(10000))
+}
+
+// fakeStreet returns a random street address
+func fakeStreet() string {
+	return fmt.Sprintf("%d %s", 1+randIntN(9999), pick(streets))
+}
+
+// fakeAddress returns a random single-line postal address
+func fakeAddress() string {
+	return fmt.Sprintf("%s, %s %05d, %s", fakeStreet(), pick(cities), randIntN(100000), pick(countries))
+}
+
+// fakeTime returns a random time within five years of fakeEpoch
+func fakeTime() time.Time {
+	return fakeEpoch.Add(time.Duration(randIntN(5*365*24*3600)) * time.Second)
+}
+
+// fakeWords returns n random lorem ipsum words
+func fakeWords(n int) string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = pick(loremWords)
+	}
+	return strings.Join(words, " ")
+}
+
+// fakeSentence returns a random lorem ipsum sentence
+func fakeSentence() string {
+	s := fakeWords(6 + randIntN(8))
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+// fakeParagraph returns a few random lorem ipsum sentences
+func fakeParagraph() string {
+	sentences := make([]string, 3+randIntN(3))
+	for i := range sentences {
+		sentences[i] = fakeSentence()
+	}
+	return strings.Join(sentences, " ")
+}
+
+// fakeInt returns a random integer in [min, max]
+func fakeInt(min, max interface{}) (int, error) {
+	lo, err := toNumber(min)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := toNumber(max)
+	if err != nil {
+		return 0, err
+	}
+	if hi < lo {
+		return 0, fmt.Errorf("fakeInt: max %v is less than min %v", hi, lo)
+	}
+	return int(lo) + randIntN(int(hi)-int(lo)+1), nil
+}
+
+// fakeFloat returns a random number in [min, max) rounded to two decimals
+func fakeFloat(min, max interface{}) (float64, error) {
+	lo, err := toNumber(min)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := toNumber(max)
+	if err != nil {
+		return 0, err
+	}
+	if hi < lo {
+		return 0, fmt.Errorf("fakeFloat: max %v is less than min %v", hi, lo)
+	}
+	f := lo + randFloat()*(hi-lo)
+	return float64(int64(f*100)) / 100, nil
+}
@@ -0,0 +1,360 @@
+// Package render renders templated response bodies. Every string in a
+// response value may contain Go template actions such as {{ .Path.id }};
+// the rest of the value is returned unchanged, except for objects of the form
+// {"$repeat": n, "$item": ...}, which render to an array of n items.
+//
+// A string made of a single call of a numeric or boolean helper, such as
+// {{ add .Index 1 }} or {{ fakeBool }}, renders to a JSON number or boolean.
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// Data is the request data available to templates
+type Data struct {
+	Method  string
+	URL     string
+	Path    map[string]string
+	Query   map[string]string
+	Headers map[string]string
+	Cookies map[string]string
+	Body    interface{}
+	RawBody string
+
+	// Index is the position of the item being rendered inside $repeat
+	Index int
+}
+
+// Value is a compiled response value
+type Value struct {
+	render func(data *Data) (interface{}, error)
+}
+
+// Compile compiles every templated string of a JSON response value
+func Compile(value interface{}) (*Value, error) {
+	return compile(value, true)
+}
+
+// CompileText compiles every templated string of a value whose strings must
+// stay strings, such as a text body or header values
+func CompileText(value interface{}) (*Value, error) {
+	return compile(value, false)
+}
+
+func compile(value interface{}, typed bool) (*Value, error) {
+	render, err := compileValue(value, typed)
+	if err != nil {
+		return nil, err
+	}
+	return &Value{render: render}, nil
+}
+
+// Render returns the response value with its templates executed
+func (v *Value) Render(data *Data) (interface{}, error) {
+	return v.render(data)
+}
+
+// compileValue compiles a value into a function producing its rendered form.
+// With typed set, single calls of typedFuncs render to numbers and booleans.
+func compileValue(value interface{}, typed bool) (func(*Data) (interface{}, error), error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return constant(v), nil
+		}
+		tmpl, err := template.New("response").Funcs(funcs).Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		typed := typed && isTypedCall(tmpl.Tree)
+		return func(data *Data) (interface{}, error) {
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, data); err != nil {
+				return nil, err
+			}
+			if typed {
+				return typedValue(sb.String()), nil
+			}
+			return sb.String(), nil
+		}, nil
+
+	case map[string]interface{}:
+		if count, ok := v["$repeat"]; ok {
+			return compileRepeat(count, v["$item"], typed)
+		}
+
+		// Fields render in sorted key order so seeded fake data is
+		// reproducible
+		keys := slices.Sorted(maps.Keys(v))
+
+		fields := make([]func(*Data) (interface{}, error), len(keys))
+		for i, key := range keys {
+			render, err := compileValue(v[key], typed)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			fields[i] = render
+		}
+		return func(data *Data) (interface{}, error) {
+			out := make(map[string]interface{}, len(fields))
+			for i, render := range fields {
+				rendered, err := render(data)
+				if err != nil {
+					return nil, err
+				}
+				out[keys[i]] = rendered
+			}
+			return out, nil
+		}, nil
+
+	case []interface{}:
+		elems := make([]func(*Data) (interface{}, error), len(v))
+		for i, child := range v {
+			render, err := compileValue(child, typed)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			elems[i] = render
+		}
+		return func(data *Data) (interface{}, error) {
+			out := make([]interface{}, len(elems))
+			for i, render := range elems {
+				rendered, err := render(data)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = rendered
+			}
+			return out, nil
+		}, nil
+
+	default:
+		return constant(v), nil
+	}
+}
+
+// maxRepeat caps $repeat so a config cannot generate unbounded responses
+const maxRepeat = 10000
+
+// compileRepeat compiles a $repeat directive. The count is a number or a
+// [min, max] range picked per request.
+func compileRepeat(count, item interface{}, typed bool) (func(*Data) (interface{}, error), error) {
+	lo, hi, err := repeatRange(count)
+	if err != nil {
+		return nil, err
+	}
+
+	render, err := compileValue(item, typed)
+	if err != nil {
+		return nil, fmt.Errorf("$item: %w", err)
+	}
+
+	return func(data *Data) (interface{}, error) {
+		n := lo
+		if hi > lo {
+			n += randIntN(hi - lo + 1)
+		}
+
+		out := make([]interface{}, n)
+		for i := range out {
+			itemData := *data
+			itemData.Index = i
+			rendered, err := render(&itemData)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}, nil
+}
+
+// repeatRange parses the count of a $repeat directive
+func repeatRange(count interface{}) (int, int, error) {
+	bounds := []interface{}{count, count}
+	if list, ok := count.([]interface{}); ok {
+		if len(list) != 2 {
+			return 0, 0, fmt.Errorf("$repeat range must be [min, max]")
+		}
+		bounds = list
+	}
+
+	var values [2]int
+	for i, b := range bounds {
+		n, ok := b.(float64)
+		if !ok || n != math.Trunc(n) || n < 0 || n > maxRepeat {
+			return 0, 0, fmt.Errorf("$repeat count must be a whole number between 0 and %d", maxRepeat)
+		}
+		values[i] = int(n)
+	}
+	if values[1] < values[0] {
+		return 0, 0, fmt.Errorf("$repeat range max is less than min")
+	}
+	return values[0], values[1], nil
+}
+
+// typedFuncs are the helpers returning numbers or booleans
+var typedFuncs = map[string]bool{
+	"add": true, "sub": true, "mul": true, "div": true, "mod": true,
+	"toNumber": true, "fakeInt": true, "fakeFloat": true, "fakeBool": true,
+}
+
+// isTypedCall reports whether a template is a single action whose result
+// comes from one of typedFuncs, e.g. {{ add .Index 1 }} or
+// {{ .Path.id | toNumber }}
+func isTypedCall(tree *parse.Tree) bool {
+	if tree == nil || len(tree.Root.Nodes) != 1 {
+		return false
+	}
+	action, ok := tree.Root.Nodes[0].(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) == 0 {
+		return false
+	}
+	last := action.Pipe.Cmds[len(action.Pipe.Cmds)-1]
+	ident, ok := last.Args[0].(*parse.IdentifierNode)
+	return ok && typedFuncs[ident.Ident]
+}
+
+// typedValue converts the output of a typed call back to a number or boolean
+func typedValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	return s
+}
+
+// constant returns a render function for a value without templates
+func constant(value interface{}) func(*Data) (interface{}, error) {
+	return func(*Data) (interface{}, error) {
+		return value, nil
+	}
+}
+
+// funcs are the helper functions available to templates
+var funcs = template.FuncMap{
+	"now":      time.Now,
+	"uuid":     newUUID,
+	"add":      add,
+	"sub":      subtract,
+	"mul":      multiply,
+	"div":      divide,
+	"mod":      modulo,
+	"toJson":   toJSON,
+	"toNumber": toNumber,
+}
+
+func init() {
+	for name, fn := range fakeFuncs {
+		funcs[name] = fn
+	}
+}
+
+// newUUID returns a random (version 4) UUID. It draws from the fake data
+// source so that seeded runs are reproducible.
+func newUUID() string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(randIntN(256))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// toNumber converts template arguments, including numeric strings such as
+// path parameters, to float64
+func toNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return 0, fmt.Errorf("'%s' is not a number", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+}
+
+// arith applies a binary operation to two numeric arguments
+func arith(a, b interface{}, op func(x, y float64) float64) (float64, error) {
+	x, err := toNumber(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := toNumber(b)
+	if err != nil {
+		return 0, err
+	}
+	return op(x, y), nil
+}
+
+// add returns a + b
+func add(a, b interface{}) (float64, error) {
+	return arith(a, b, func(x, y float64) float64 { return x + y })
+}
+
+// subtract returns a - b
+func subtract(a, b interface{}) (float64, error) {
+	return arith(a, b, func(x, y float64) float64 { return x - y })
+}
+
+// multiply returns a * b
+func multiply(a, b interface{}) (float64, error) {
+	return arith(a, b, func(x, y float64) float64 { return x * y })
+}
+
+// divide divides a by b, rejecting division by zero
+func divide(a, b interface{}) (float64, error) {
+	y, err := toNumber(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return arith(a, y, func(x, y float64) float64 { return x / y })
+}
+
+// modulo returns the remainder of a divided by b
+func modulo(a, b interface{}) (float64, error) {
+	y, err := toNumber(b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	return arith(a, y, math.Mod)
+}
+
+// toJSON returns the JSON text of a value
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,235 @@
+package render
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testData is the request data the tests render with
+var testData = &Data{
+	Method: "GET",
+	Path:   map[string]string{"id": "7", "pid": "007"},
+	Query:  map[string]string{"q": "x"},
+	Body:   map[string]interface{}{"n": 2.0, "name": "Ada"},
+}
+
+// decode parses a JSON test value
+func decode(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", text, err)
+	}
+	return value
+}
+
+// render compiles and renders a value, returning its JSON text
+func render(t *testing.T, compile func(interface{}) (*Value, error), value interface{}) (string, error) {
+	t.Helper()
+	compiled, err := compile(value)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	rendered, err := compiled.Render(testData)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), nil
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "no template", value: `{"a": "b", "n": 1, "ok": true, "x": null}`, want: `{"a":"b","n":1,"ok":true,"x":null}`},
+		{name: "request data", value: `"{{ .Method }} {{ .Path.id }} {{ .Query.q }}"`, want: `"GET 7 x"`},
+		{name: "path parameter stays a string", value: `"{{ .Path.pid }}"`, want: `"007"`},
+		{name: "add renders a number", value: `"{{ add .Path.id 1 }}"`, want: `8`},
+		{name: "arithmetic", value: `["{{ sub 5 2 }}", "{{ mul 2 3 }}", "{{ div 7 2 }}", "{{ mod 7 2 }}"]`, want: `[3,6,3.5,1]`},
+		{name: "toNumber renders a number", value: `"{{ .Path.pid | toNumber }}"`, want: `7`},
+		{name: "body number", value: `"{{ add .Body.n 0 }}"`, want: `2`},
+		{name: "typed call with text is a string", value: `"id {{ add .Path.id 1 }}"`, want: `"id 8"`},
+		{name: "two calls are a string", value: `"{{ add 1 1 }}{{ add 1 1 }}"`, want: `"22"`},
+		{name: "untyped helper is a string", value: `"{{ toJson .Body.n }}"`, want: `"2"`},
+		{name: "variable declaration is a string", value: `"{{ $x := add 1 1 }}{{ $x }}"`, want: `"2"`},
+		{name: "nested values", value: `{"user": {"id": "{{ toNumber .Path.id }}", "tags": ["{{ .Body.name }}"]}}`, want: `{"user":{"id":7,"tags":["Ada"]}}`},
+		{name: "repeat", value: `{"$repeat": 3, "$item": {"i": "{{ .Index }}", "n": "{{ add .Index 1 }}"}}`, want: `[{"i":"0","n":1},{"i":"1","n":2},{"i":"2","n":3}]`},
+		{name: "repeat zero", value: `{"$repeat": 0, "$item": 1}`, want: `[]`},
+		{name: "repeat of a constant", value: `{"$repeat": [2, 2], "$item": "x"}`, want: `["x","x"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(t, Compile, decode(t, tt.value))
+			if err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeTyped(t *testing.T) {
+	compiled, err := Compile(decode(t, `{"b": "{{ fakeBool }}", "f": "{{ fakeFloat 1 2 }}", "i": "{{ fakeInt 1 2 }}"}`))
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	rendered, err := compiled.Render(testData)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	out := rendered.(map[string]interface{})
+	if _, ok := out["b"].(bool); !ok {
+		t.Errorf("fakeBool rendered %#v, want a boolean", out["b"])
+	}
+	for _, key := range []string{"f", "i"} {
+		if n, ok := out[key].(float64); !ok || n < 1 || n > 2 {
+			t.Errorf("%s rendered %#v, want a number from 1 to 2", key, out[key])
+		}
+	}
+}
+
+func TestCompileText(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "typed call stays a string", value: `"{{ add .Path.id 1 }}"`, want: `"8"`},
+		{name: "boolean stays a string", value: `"{{ eq .Method \"GET\" }}"`, want: `"true"`},
+		{name: "header values", value: `{"X-Id": "{{ toNumber .Path.pid }}"}`, want: `{"X-Id":"7"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := render(t, CompileText, decode(t, tt.value))
+			if err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatRange(t *testing.T) {
+	compiled, err := Compile(decode(t, `{"$repeat": [2, 4], "$item": 1}`))
+	if err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+	seen := make(map[int]bool)
+	for range 200 {
+		rendered, err := compiled.Render(testData)
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		n := len(rendered.([]interface{}))
+		if n < 2 || n > 4 {
+			t.Fatalf("rendered %d items, want 2 to 4", n)
+		}
+		seen[n] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("rendered counts %v, want every count from 2 to 4", seen)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "template syntax", value: `{"a": "{{ .Path.id "}`},
+		{name: "unknown function", value: `"{{ nope }}"`},
+		{name: "negative repeat", value: `{"$repeat": -1, "$item": 1}`},
+		{name: "fractional repeat", value: `{"$repeat": 1.5, "$item": 1}`},
+		{name: "repeat too large", value: `{"$repeat": 10001, "$item": 1}`},
+		{name: "repeat not a number", value: `{"$repeat": "3", "$item": 1}`},
+		{name: "repeat range of one", value: `{"$repeat": [1], "$item": 1}`},
+		{name: "repeat range reversed", value: `{"$repeat": [3, 1], "$item": 1}`},
+		{name: "repeat item template", value: `{"$repeat": 1, "$item": "{{ "}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile(decode(t, tt.value)); err == nil {
+				t.Errorf("Compile(%s) succeeded, want an error", tt.value)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "division by zero", value: `"{{ div 1 0 }}"`},
+		{name: "modulo by zero", value: `"{{ mod 1 0 }}"`},
+		{name: "not a number", value: `"{{ add .Body.name 1 }}"`},
+		{name: "toNumber of text", value: `"{{ toNumber .Query.q }}"`},
+		{name: "error inside repeat", value: `{"$repeat": 2, "$item": "{{ div .Index 0 }}"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := render(t, Compile, decode(t, tt.value)); err == nil {
+				t.Errorf("Render() = %s, want an error", got)
+			}
+		})
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{in: "true", want: true},
+		{in: "false", want: false},
+		{in: "42", want: 42.0},
+		{in: "-1.5", want: -1.5},
+		{in: "+Inf", want: "+Inf"},
+		{in: "NaN", want: "NaN"},
+		{in: "", want: ""},
+		{in: "abc", want: "abc"},
+	}
+	for _, tt := range tests {
+		if got := typedValue(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("typedValue(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	value := decode(t, `{
+		"id": "{{ uuid }}",
+		"name": "{{ fakeName }}",
+		"email": "{{ fakeEmail }}",
+		"age": "{{ fakeInt 18 99 }}",
+		"items": {"$repeat": [1, 5], "$item": "{{ fakeWords 3 }}"}
+	}`)
+
+	renderSeeded := func(seed uint64) string {
+		Seed(seed)
+		got, err := render(t, Compile, value)
+		if err != nil {
+			t.Fatalf("Render() failed: %v", err)
+		}
+		return got
+	}
+
+	first := renderSeeded(42)
+	if again := renderSeeded(42); again != first {
+		t.Errorf("the same seed rendered %s, then %s", first, again)
+	}
+	if other := renderSeeded(43); other == first {
+		t.Errorf("seeds 42 and 43 both rendered %s", first)
+	}
+}
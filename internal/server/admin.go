@@ -0,0 +1,73 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// SetAdminToken sets the token that admin requests changing the server must
+// carry as "Authorization: Bearer <token>". Without one, the reload endpoint
+// is not served and runtime routes cannot be changed. It must be called
+// before Start.
+func (s *Server) SetAdminToken(token string) {
+	s.adminToken = token
+}
+
+// SetReloadHook sets how POST /__mockr/reload loads the config again. The
+// endpoint is only served when an admin token is set too. It must be called
+// before Start.
+func (s *Server) SetReloadHook(reload func() error) {
+	s.reload = reload
+}
+
+// adminReloadEnabled reports whether the admin reload endpoint is served
+func (s *Server) adminReloadEnabled() bool {
+	return s.adminToken != "" && s.reload != nil
+}
+
+// authorized reports whether a request carries the admin token
+func (s *Server) authorized(r *http.Request) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminToken != "" && subtle.ConstantTimeCompare([]byte(given), []byte(s.adminToken)) == 1
+}
+
+// requireAdmin answers and returns false unless the request carries the admin
+// token: 403 if no token is set, so admin changes are disabled, and 401
+// otherwise
+func (s *Server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.adminToken == "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Admin changes are disabled, set an admin token to enable them"})
+		return false
+	}
+	if s.authorized(r) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="mockr"`)
+	writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+	return false
+}
+
+// reloadHandler handles POST /__mockr/reload, reloading the config the same
+// way a config change or SIGHUP does
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+	if !s.requireAdmin(w, r) {
+		return
+	}
+
+	// The last good config keeps being served if the reload fails
+	if err := s.reload(); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	routes := len(s.config.Routes)
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reloaded": true, "routes": routes})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// bodyContextKey is the context key of the buffered request body
+type bodyContextKey struct{}
+
+// requestBody is a request body read once and shared by matchers and
+// handlers. The JSON form is decoded lazily, at most once.
+type requestBody struct {
+	raw []byte
+
+	once    sync.Once
+	json    interface{}
+	jsonErr error
+}
+
+// JSON returns the body decoded as JSON
+func (b *requestBody) JSON() (interface{}, error) {
+	b.once.Do(func() {
+		if len(bytes.TrimSpace(b.raw)) == 0 {
+			b.jsonErr = io.EOF
+			return
+		}
+		b.jsonErr = json.Unmarshal(b.raw, &b.json)
+	})
+	return b.json, b.jsonErr
+}
+
+// withBufferedBody reads the request body and returns a request carrying it.
+// The returned request's Body can be read again by handlers.
+func withBufferedBody(r *http.Request) (*http.Request, error) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	ctx := context.WithValue(r.Context(), bodyContextKey{}, &requestBody{raw: raw})
+	return r.WithContext(ctx), nil
+}
+
+// bufferedBody returns the body buffered for the request, or an empty body
+// when none was buffered
+func bufferedBody(r *http.Request) *requestBody {
+	if body, ok := r.Context().Value(bodyContextKey{}).(*requestBody); ok {
+		return body
+	}
+	return &requestBody{}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reserved query parameters of collection queries. Any other parameter
+// filters items on the field of the same name.
+const (
+	pageParam  = "_page"
+	limitParam = "_limit"
+	sortParam  = "_sort"
+	orderParam = "_order"
+	textParam  = "q"
+)
+
+// defaultPageLimit is the page size when _page is given without _limit
+const defaultPageLimit = 10
+
+// queryCollection filters, sorts and paginates a JSON array according to the
+// request query, json-server style. It sets X-Total-Count to the number of
+// items matching the filters and, when paginating, a Link header.
+func queryCollection(w http.ResponseWriter, r *http.Request, items []interface{}) ([]interface{}, error) {
+	query := r.URL.Query()
+
+	page, err := positiveParam(query, pageParam)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := positiveParam(query, limitParam)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter on field equality and full-text search
+	result := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		if matchesFilters(item, query) {
+			result = append(result, item)
+		}
+	}
+
+	// Sort on one or more comma-separated fields
+	if fields := query.Get(sortParam); fields != "" {
+		sortItems(result, strings.Split(fields, ","), strings.Split(query.Get(orderParam), ","))
+	}
+
+	total := len(result)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	// Paginate
+	if page == 0 && limit == 0 {
+		return result, nil
+	}
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+
+	// Page and limit may be huge, so nothing here may overflow
+	lastPage := 1
+	if total > 0 {
+		lastPage = (total-1)/limit + 1
+	}
+	w.Header().Set("Link", pageLinks(r, page, lastPage))
+
+	if total == 0 || page > lastPage {
+		return []interface{}{}, nil
+	}
+	start := (page - 1) * limit
+	end := total
+	if limit < total-start {
+		end = start + limit
+	}
+	return result[start:end], nil
+}
+
+// positiveParam parses an optional positive integer query parameter,
+// returning 0 when it is absent
+func positiveParam(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// matchesFilters reports whether an item matches every field filter and the
+// full-text search. A filter with several values matches any of them.
+func matchesFilters(item interface{}, query url.Values) bool {
+	for name, values := range query {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		if name == textParam {
+			if !containsText(item, strings.ToLower(values[0])) {
+				return false
+			}
+			continue
+		}
+
+		field := fieldString(lookupField(item, name))
+		if !anyValue(values, func(v string) bool { return v == field }) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupField returns a field of an object, following dots into nested
+// objects (e.g. author.name)
+func lookupField(item interface{}, name string) interface{} {
+	for _, part := range strings.Split(name, ".") {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		item = obj[part]
+	}
+	return item
+}
+
+// fieldString formats a field value for comparison with a query value
+func fieldString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return idString(v)
+	}
+}
+
+// containsText reports whether any string or number inside the value
+// contains the lower-case text
+func containsText(value interface{}, text string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			if containsText(child, text) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if containsText(child, text) {
+				return true
+			}
+		}
+	case nil:
+	default:
+		return strings.Contains(strings.ToLower(fieldString(v)), text)
+	}
+	return false
+}
+
+// sortItems sorts items on the given fields, each ascending unless the
+// matching order is "desc". Items missing a field sort last.
+func sortItems(items []interface{}, fields, orders []string) {
+	sort.SliceStable(items, func(i, j int) bool {
+		for k, field := range fields {
+			field = strings.TrimSpace(field)
+			a := lookupField(items[i], field)
+			b := lookupField(items[j], field)
+
+			cmp := compareValues(a, b)
+			if cmp == 0 {
+				continue
+			}
+			// Missing values stay last regardless of order
+			if a == nil || b == nil {
+				return b == nil
+			}
+			if k < len(orders) && strings.EqualFold(strings.TrimSpace(orders[k]), "desc") {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// compareValues orders two field values: numbers numerically, anything else
+// by its text
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+	if x, ok := a.(float64); ok {
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fieldString(a), fieldString(b))
+}
+
+// pageLinks builds the Link header pointing to the first, previous, next and
+// last pages
+func pageLinks(r *http.Request, page, lastPage int) string {
+	link := func(p int, rel string) string {
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path}
+		query := r.URL.Query()
+		query.Set(pageParam, strconv.Itoa(p))
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=\"%s\"", u.String(), rel)
+	}
+
+	links := []string{link(1, "first")}
+	if page > 1 {
+		links = append(links, link(page-1, "prev"))
+	}
+	if page < lastPage {
+		links = append(links, link(page+1, "next"))
+	}
+	links = append(links, link(lastPage, "last"))
+	return strings.Join(links, ", ")
+}
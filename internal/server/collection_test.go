@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestQueryCollection(t *testing.T) {
+	const data = `[
+		{"id": 1, "name": "Ada", "role": "admin", "age": 36, "author": {"name": "Lovelace"}},
+		{"id": 2, "name": "Alan", "role": "user", "age": 41, "author": {"name": "Turing"}},
+		{"id": 3, "name": "Grace", "role": "user", "age": 85},
+		{"id": 4, "name": "Linus", "role": "admin", "age": 9},
+		{"id": 5, "name": "Barbara", "age": 41}
+	]`
+	var items []interface{}
+	if err := json.Unmarshal([]byte(data), &items); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		ids     []int
+		total   string
+		link    string
+		wantErr bool
+	}{
+		{name: "no query", query: "", ids: []int{1, 2, 3, 4, 5}, total: "5"},
+		{name: "filter", query: "role=admin", ids: []int{1, 4}, total: "2"},
+		{name: "filter on a number", query: "age=41", ids: []int{2, 5}, total: "2"},
+		{name: "filter with several values", query: "id=1&id=3", ids: []int{1, 3}, total: "2"},
+		{name: "filters combine", query: "role=user&age=41", ids: []int{2}, total: "1"},
+		{name: "filter on a nested field", query: "author.name=Turing", ids: []int{2}, total: "1"},
+		{name: "filter on a missing field", query: "role=", ids: []int{5}, total: "1"},
+		{name: "no match", query: "role=guest", ids: []int{}, total: "0"},
+		{name: "full-text search", query: "q=LOVE", ids: []int{1}, total: "1"},
+		{name: "full-text search on numbers", query: "q=85", ids: []int{3}, total: "1"},
+		{name: "sort", query: "_sort=name", ids: []int{1, 2, 5, 3, 4}, total: "5"},
+		{name: "sort numerically", query: "_sort=age", ids: []int{4, 1, 2, 5, 3}, total: "5"},
+		{name: "sort descending", query: "_sort=age&_order=desc", ids: []int{3, 2, 5, 1, 4}, total: "5"},
+		{name: "sort on several fields", query: "_sort=age,name&_order=desc,asc", ids: []int{3, 2, 5, 1, 4}, total: "5"},
+		{name: "sort with missing fields last", query: "_sort=role&_order=desc", ids: []int{2, 3, 1, 4, 5}, total: "5"},
+		{
+			name:  "first page",
+			query: "_page=1&_limit=2",
+			ids:   []int{1, 2},
+			total: "5",
+			link:  `<http://example.com/users?_limit=2&_page=1>; rel="first", <http://example.com/users?_limit=2&_page=2>; rel="next", <http://example.com/users?_limit=2&_page=3>; rel="last"`,
+		},
+		{
+			name:  "last page",
+			query: "_page=3&_limit=2",
+			ids:   []int{5},
+			total: "5",
+			link:  `<http://example.com/users?_limit=2&_page=1>; rel="first", <http://example.com/users?_limit=2&_page=2>; rel="prev", <http://example.com/users?_limit=2&_page=3>; rel="last"`,
+		},
+		{name: "page past the end", query: "_page=4&_limit=2", ids: []int{}, total: "5"},
+		{name: "limit without page", query: "_limit=3", ids: []int{1, 2, 3}, total: "5"},
+		{name: "page without limit", query: "_page=1", ids: []int{1, 2, 3, 4, 5}, total: "5"},
+		{name: "filter, sort and paginate", query: "role=admin&_sort=age&_limit=1", ids: []int{4}, total: "2"},
+		{name: "huge limit", query: "_page=1&_limit=9223372036854775807", ids: []int{1, 2, 3, 4, 5}, total: "5"},
+		{name: "huge limit past the end", query: "_page=3&_limit=4611686018427387904", ids: []int{}, total: "5"},
+		{name: "huge page", query: "_page=9223372036854775807&_limit=2", ids: []int{}, total: "5"},
+		{name: "page of an empty result", query: "role=guest&_page=1", ids: []int{}, total: "0"},
+		{name: "invalid page", query: "_page=0", wantErr: true},
+		{name: "invalid limit", query: "_limit=ten", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://example.com/users?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			result, err := queryCollection(w, r, items)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("queryCollection() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("queryCollection() failed: %v", err)
+			}
+
+			ids := []int{}
+			for _, item := range result {
+				ids = append(ids, int(item.(map[string]interface{})["id"].(float64)))
+			}
+			if !slices.Equal(ids, tt.ids) {
+				t.Errorf("ids = %v, want %v", ids, tt.ids)
+			}
+			if got := w.Header().Get("X-Total-Count"); got != tt.total {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.total)
+			}
+			if tt.link != "" {
+				if got := w.Header().Get("Link"); got != tt.link {
+					t.Errorf("Link = %s, want %s", got, tt.link)
+				}
+			}
+		})
+	}
+}
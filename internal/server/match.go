@@ -0,0 +1,320 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+
+	"github.com/abdillahi-nur/mockr/internal/jsonpath"
+)
+
+// Match holds the request matchers of a route beyond its method and path
+type Match struct {
+	PathRegex string                  `json:"pathRegex,omitempty"`
+	Query     map[string]ValueMatcher `json:"query,omitempty"`
+	Headers   map[string]ValueMatcher `json:"headers,omitempty"`
+	Cookies   map[string]ValueMatcher `json:"cookies,omitempty"`
+	Body      *BodyMatcher            `json:"body,omitempty"`
+}
+
+// BodyMatcher matches the request body
+type BodyMatcher struct {
+	EqualsJSON   interface{}             `json:"equalsJson,omitempty"`
+	ContainsJSON interface{}             `json:"containsJson,omitempty"`
+	JSONPath     map[string]ValueMatcher `json:"jsonPath,omitempty"`
+	Regex        string                  `json:"regex,omitempty"`
+}
+
+// ValueMatcher matches the values of a named request attribute
+type ValueMatcher struct {
+	Equals  *string `json:"equals,omitempty"`
+	Present *bool   `json:"present,omitempty"`
+	Regex   string  `json:"regex,omitempty"`
+}
+
+// valueMatcher is a compiled ValueMatcher for a named attribute
+type valueMatcher struct {
+	name    string
+	equals  *string
+	present *bool
+	regex   *regexp.Regexp
+}
+
+// requestMatcher is a compiled Match, evaluated after method and path
+type requestMatcher struct {
+	query   []valueMatcher
+	headers []valueMatcher
+	cookies []valueMatcher
+	body    *bodyMatcher
+}
+
+// bodyMatcher is a compiled BodyMatcher
+type bodyMatcher struct {
+	equalsJSON   interface{}
+	containsJSON interface{}
+	jsonPaths    []jsonPathMatcher
+	regex        *regexp.Regexp
+}
+
+// jsonPathMatcher matches the values selected by a JSONPath expression
+type jsonPathMatcher struct {
+	path  *jsonpath.Path
+	value valueMatcher
+}
+
+// compileMatch compiles the request matchers of a route
+func compileMatch(m *Match) (*requestMatcher, error) {
+	rm := &requestMatcher{}
+	if m == nil {
+		return rm, nil
+	}
+
+	for name, vm := range m.Query {
+		compiled, err := compileValueMatcher(name, vm)
+		if err != nil {
+			return nil, fmt.Errorf("query parameter '%s': %w", name, err)
+		}
+		rm.query = append(rm.query, compiled)
+	}
+
+	for name, vm := range m.Headers {
+		compiled, err := compileValueMatcher(http.CanonicalHeaderKey(name), vm)
+		if err != nil {
+			return nil, fmt.Errorf("header '%s': %w", name, err)
+		}
+		rm.headers = append(rm.headers, compiled)
+	}
+
+	for name, vm := range m.Cookies {
+		compiled, err := compileValueMatcher(name, vm)
+		if err != nil {
+			return nil, fmt.Errorf("cookie '%s': %w", name, err)
+		}
+		rm.cookies = append(rm.cookies, compiled)
+	}
+
+	if m.Body != nil {
+		body, err := compileBodyMatcher(m.Body)
+		if err != nil {
+			return nil, err
+		}
+		rm.body = body
+	}
+
+	return rm, nil
+}
+
+// compileBodyMatcher compiles the request body matchers
+func compileBodyMatcher(b *BodyMatcher) (*bodyMatcher, error) {
+	bm := &bodyMatcher{
+		equalsJSON:   b.EqualsJSON,
+		containsJSON: b.ContainsJSON,
+	}
+
+	for expr, vm := range b.JSONPath {
+		path, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("body jsonPath '%s': %w", expr, err)
+		}
+		compiled, err := compileValueMatcher(expr, vm)
+		if err != nil {
+			return nil, fmt.Errorf("body jsonPath '%s': %w", expr, err)
+		}
+		bm.jsonPaths = append(bm.jsonPaths, jsonPathMatcher{path: path, value: compiled})
+	}
+
+	if b.Regex != "" {
+		re, err := regexp.Compile(b.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("body regex: %w", err)
+		}
+		bm.regex = re
+	}
+
+	return bm, nil
+}
+
+// compileValueMatcher compiles a single value matcher
+func compileValueMatcher(name string, vm ValueMatcher) (valueMatcher, error) {
+	compiled := valueMatcher{name: name, equals: vm.Equals, present: vm.Present}
+	if vm.Regex != "" {
+		re, err := regexp.Compile(vm.Regex)
+		if err != nil {
+			return valueMatcher{}, err
+		}
+		compiled.regex = re
+	}
+	return compiled, nil
+}
+
+// count returns the number of matchers, used to rank routes sharing a path
+func (rm *requestMatcher) count() int {
+	count := len(rm.query) + len(rm.headers) + len(rm.cookies)
+	if b := rm.body; b != nil {
+		count += len(b.jsonPaths)
+		if b.equalsJSON != nil {
+			count++
+		}
+		if b.containsJSON != nil {
+			count++
+		}
+		if b.regex != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// matches reports whether the request satisfies every matcher
+func (rm *requestMatcher) matches(r *http.Request) bool {
+	if len(rm.query) > 0 {
+		query := r.URL.Query()
+		for _, vm := range rm.query {
+			if !vm.matches(query[vm.name]) {
+				return false
+			}
+		}
+	}
+
+	for _, vm := range rm.headers {
+		if !vm.matches(r.Header.Values(vm.name)) {
+			return false
+		}
+	}
+
+	if len(rm.cookies) > 0 {
+		cookies := make(map[string][]string)
+		for _, c := range r.Cookies() {
+			cookies[c.Name] = append(cookies[c.Name], c.Value)
+		}
+		for _, vm := range rm.cookies {
+			if !vm.matches(cookies[vm.name]) {
+				return false
+			}
+		}
+	}
+
+	if rm.body != nil && !rm.body.matches(bufferedBody(r)) {
+		return false
+	}
+
+	return true
+}
+
+// matches reports whether the buffered body satisfies every body matcher
+func (bm *bodyMatcher) matches(body *requestBody) bool {
+	if bm.regex != nil && !bm.regex.Match(body.raw) {
+		return false
+	}
+
+	if bm.equalsJSON == nil && bm.containsJSON == nil && len(bm.jsonPaths) == 0 {
+		return true
+	}
+
+	doc, err := body.JSON()
+	if err != nil {
+		return false
+	}
+
+	if bm.equalsJSON != nil && !reflect.DeepEqual(doc, bm.equalsJSON) {
+		return false
+	}
+	if bm.containsJSON != nil && !containsJSON(doc, bm.containsJSON) {
+		return false
+	}
+	for _, jm := range bm.jsonPaths {
+		if !jm.value.matches(jsonValuesToStrings(jm.path.Eval(doc))) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsJSON reports whether actual contains expected: objects may have
+// extra members, and every expected array element must be contained in some
+// actual element
+func containsJSON(actual, expected interface{}) bool {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, expValue := range exp {
+			actValue, ok := act[key]
+			if !ok || !containsJSON(actValue, expValue) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, expElem := range exp {
+			found := false
+			for _, actElem := range act {
+				if containsJSON(actElem, expElem) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, expected)
+	}
+}
+
+// jsonValuesToStrings converts selected JSON values for value matching.
+// Strings are used as is, anything else as its JSON text.
+func jsonValuesToStrings(values []interface{}) []string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			strs = append(strs, s)
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		strs = append(strs, string(data))
+	}
+	return strs
+}
+
+// matches checks the values of the attribute against every condition. A
+// value condition holds when any of the values satisfies it.
+func (vm valueMatcher) matches(values []string) bool {
+	if vm.present != nil && *vm.present != (len(values) > 0) {
+		return false
+	}
+	if vm.present == nil && vm.equals == nil && vm.regex == nil && len(values) == 0 {
+		return false
+	}
+	if vm.equals != nil && !anyValue(values, func(v string) bool { return v == *vm.equals }) {
+		return false
+	}
+	if vm.regex != nil && !anyValue(values, vm.regex.MatchString) {
+		return false
+	}
+	return true
+}
+
+// anyValue reports whether any value satisfies the predicate
+func anyValue(values []string, pred func(string) bool) bool {
+	for _, v := range values {
+		if pred(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// persistDelay debounces snapshots so bursts of writes cause a single save
+const persistDelay = 500 * time.Millisecond
+
+// dataPersister snapshots resource collections to a data file
+type dataPersister struct {
+	path string
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending *resourceStore
+}
+
+// SetDataFile makes resource collections persist to the given file. The
+// file is loaded when the server starts and rewritten after every change.
+func (s *Server) SetDataFile(path string) {
+	s.persist = &dataPersister{path: path}
+}
+
+// DataFile returns the path of the data file, or "" when none is set
+func (s *Server) DataFile() string {
+	if s.persist == nil {
+		return ""
+	}
+	return s.persist.path
+}
+
+// load replaces the collections of the store with those saved in the data
+// file. Collections that are no longer configured are ignored.
+func (p *dataPersister) load(store *resourceStore) error {
+	data, err := os.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading data file: %w", err)
+	}
+
+	var snapshot map[string][]map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("error parsing data file: %w", err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for base, items := range snapshot {
+		if _, ok := store.collections[base]; !ok {
+			continue
+		}
+		c := newCollection()
+		for _, item := range items {
+			c.add(item)
+		}
+		store.collections[base] = c
+	}
+
+	return nil
+}
+
+// schedule saves the store once no change has happened for persistDelay
+func (p *dataPersister) schedule(store *resourceStore) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending = store
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(persistDelay, p.flush)
+}
+
+// flush saves a pending snapshot right away
+func (p *dataPersister) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if p.pending == nil {
+		return
+	}
+
+	if err := p.save(p.pending); err != nil {
+		log.Printf("Error saving data file: %v", err)
+		return
+	}
+	p.pending = nil
+}
+
+// save writes a snapshot of the store atomically: to a temporary file in the
+// same directory, renamed over the data file
+func (p *dataPersister) save(store *resourceStore) error {
+	store.mu.RLock()
+	snapshot := make(map[string][]map[string]interface{}, len(store.collections))
+	for base, c := range store.collections {
+		snapshot[base] = c.items
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	store.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), "."+filepath.Base(p.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p.path)
+}
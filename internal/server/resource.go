@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// resourceStore holds the in-memory collections of resource mode. It is
+// rebuilt from the seed items whenever the config is (re)loaded.
+type resourceStore struct {
+	mu          sync.RWMutex
+	collections map[string]*collection
+
+	// onChange is called after every change, e.g. to persist the store
+	onChange func()
+}
+
+// collection is the list of items of one resource
+type collection struct {
+	items  []map[string]interface{}
+	nextID int64
+}
+
+// newResourceStore creates a store holding a copy of the seed items
+func newResourceStore(resources map[string][]map[string]interface{}) *resourceStore {
+	store := &resourceStore{collections: make(map[string]*collection)}
+	for base, items := range resources {
+		c := newCollection()
+		for _, item := range items {
+			c.add(copyItem(item))
+		}
+		store.collections[base] = c
+	}
+	return store
+}
+
+// bases returns the base paths of all collections in sorted order
+func (store *resourceStore) bases() []string {
+	return slices.Sorted(maps.Keys(store.collections))
+}
+
+// newCollection creates an empty collection
+func newCollection() *collection {
+	return &collection{items: []map[string]interface{}{}, nextID: 1}
+}
+
+// changed notifies the store's observer of a change
+func (store *resourceStore) changed() {
+	if store.onChange != nil {
+		store.onChange()
+	}
+}
+
+// add appends an item, assigning the next free numeric id when it has none.
+// An id is free when no item has it, as a number or as a string.
+func (c *collection) add(item map[string]interface{}) {
+	if _, ok := item["id"]; !ok {
+		for c.find(strconv.FormatInt(c.nextID, 10)) >= 0 {
+			c.nextID++
+		}
+		item["id"] = c.nextID
+	}
+	if id, ok := numericID(item["id"]); ok && id >= c.nextID {
+		c.nextID = id + 1
+	}
+	c.items = append(c.items, item)
+}
+
+// find returns the index of the item with the given id, or -1
+func (c *collection) find(id string) int {
+	for i, item := range c.items {
+		if idString(item["id"]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// numericID returns an id as an integer when it is a whole number
+func numericID(id interface{}) (int64, bool) {
+	switch v := id.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return int64(v), true
+		}
+	case int64:
+		return v, true
+	}
+	return 0, false
+}
+
+// idString formats an id the way it appears in a URL
+func idString(id interface{}) string {
+	if n, ok := numericID(id); ok {
+		return strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprint(id)
+}
+
+// copyItem returns a deep copy of an item, so that seed data is never
+// modified by requests
+func copyItem(item map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(item)
+	var copied map[string]interface{}
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// resourceRoutes returns the CRUD routes of a resource, with their handlers
+func (s *Server) resourceRoutes(store *resourceStore, base string) []*routeEntry {
+	itemPath := base + "/{id}"
+	return []*routeEntry{
+		s.resourceEntry("GET", base, func(w http.ResponseWriter, r *http.Request) { store.list(w, r, base) }),
+		s.resourceEntry("POST", base, func(w http.ResponseWriter, r *http.Request) { store.create(w, r, base) }),
+		s.resourceEntry("GET", itemPath, func(w http.ResponseWriter, r *http.Request) { store.get(w, r, base) }),
+		s.resourceEntry("PUT", itemPath, func(w http.ResponseWriter, r *http.Request) { store.update(w, r, base, false) }),
+		s.resourceEntry("PATCH", itemPath, func(w http.ResponseWriter, r *http.Request) { store.update(w, r, base, true) }),
+		s.resourceEntry("DELETE", itemPath, func(w http.ResponseWriter, r *http.Request) { store.remove(w, r, base) }),
+	}
+}
+
+// list handles GET /base, returning the items selected by the collection
+// query parameters (filters, sorting and pagination)
+func (store *resourceStore) list(w http.ResponseWriter, r *http.Request, base string) {
+	store.mu.RLock()
+	items := make([]interface{}, len(store.collections[base].items))
+	for i, item := range store.collections[base].items {
+		items[i] = item
+	}
+	store.mu.RUnlock()
+
+	result, err := queryCollection(w, r, items)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// get handles GET /base/{id}
+func (store *resourceStore) get(w http.ResponseWriter, r *http.Request, base string) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	c := store.collections[base]
+	idx := c.find(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, c.items[idx])
+}
+
+// create handles POST /base. Items without an id get the next numeric one.
+func (store *resourceStore) create(w http.ResponseWriter, r *http.Request, base string) {
+	item, ok := decodeItem(w, r)
+	if !ok {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	c := store.collections[base]
+	if id, ok := item["id"]; ok && c.find(idString(id)) >= 0 {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Resource already exists"})
+		return
+	}
+	c.add(item)
+	store.changed()
+
+	w.Header().Set("Location", base+"/"+idString(item["id"]))
+	writeJSON(w, http.StatusCreated, item)
+}
+
+// update handles PUT /base/{id}, replacing the item, and PATCH /base/{id},
+// merging the given fields into it. The id cannot be changed.
+func (store *resourceStore) update(w http.ResponseWriter, r *http.Request, base string, merge bool) {
+	fields, ok := decodeItem(w, r)
+	if !ok {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	c := store.collections[base]
+	idx := c.find(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+		return
+	}
+
+	// Stored items are never modified in place, so readers may keep using
+	// them after releasing the lock
+	item := fields
+	if merge {
+		item = make(map[string]interface{})
+		for key, value := range c.items[idx] {
+			item[key] = value
+		}
+		for key, value := range fields {
+			item[key] = value
+		}
+	}
+	item["id"] = c.items[idx]["id"]
+	c.items[idx] = item
+	store.changed()
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// remove handles DELETE /base/{id}
+func (store *resourceStore) remove(w http.ResponseWriter, r *http.Request, base string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	c := store.collections[base]
+	idx := c.find(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Resource not found"})
+		return
+	}
+	c.items = append(c.items[:idx], c.items[idx+1:]...)
+	store.changed()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeItem decodes the request body as a JSON object, answering 400 when
+// it is not one
+func decodeItem(w http.ResponseWriter, r *http.Request) (map[string]interface{}, bool) {
+	doc, err := bufferedBody(r).JSON()
+	item, ok := doc.(map[string]interface{})
+	if err != nil || !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "request body must be a JSON object"})
+		return nil, false
+	}
+	return copyItem(item), true
+}
+
+// writeJSON writes a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
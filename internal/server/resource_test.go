@@ -0,0 +1,37 @@
+package server
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCollectionAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		seed []map[string]interface{}
+		want []string // ids after adding an item without one
+	}{
+		{name: "empty", want: []string{"1"}},
+		{name: "numeric ids", seed: []map[string]interface{}{{"id": 1.0}, {"id": 5.0}}, want: []string{"1", "5", "6"}},
+		{name: "string id", seed: []map[string]interface{}{{"id": "1"}}, want: []string{"1", "2"}},
+		{name: "string ids in a row", seed: []map[string]interface{}{{"id": "1"}, {"id": "2"}, {"id": "4"}}, want: []string{"1", "2", "4", "3"}},
+		{name: "non-numeric string id", seed: []map[string]interface{}{{"id": "abc"}}, want: []string{"abc", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCollection()
+			for _, item := range tt.seed {
+				c.add(item)
+			}
+			c.add(map[string]interface{}{})
+
+			var ids []string
+			for _, item := range c.items {
+				ids = append(ids, idString(item["id"]))
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
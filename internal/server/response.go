@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/abdillahi-nur/mockr/internal/render"
+)
+
+// ResponseEntry is one of several responses of a route
+type ResponseEntry struct {
+	Status      int               `json:"status,omitempty"`
+	Delay       int               `json:"delay,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Response    interface{}       `json:"response"`
+	Body        string            `json:"body,omitempty"`
+	BodyBase64  string            `json:"bodyBase64,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+}
+
+// Content types of the different kinds of bodies
+const (
+	jsonContentType   = "application/json; charset=utf-8"
+	textContentType   = "text/plain; charset=utf-8"
+	binaryContentType = "application/octet-stream"
+)
+
+// cannedResponse is a response a route can send. With templating enabled
+// the body and headers are compiled once and rendered per request.
+type cannedResponse struct {
+	status      int
+	delay       int
+	headers     map[string]string
+	contentType string
+
+	// explicitType is set when the route chose contentType, which then wins
+	// over a Content-Type from the default headers
+	explicitType bool
+
+	// body is a value encoded as JSON, or with raw set, a string or []byte
+	// sent as is
+	body        interface{}
+	raw         bool
+	bodyTmpl    *render.Value
+	headersTmpl *render.Value
+}
+
+// responseSelector picks the response for each call to a route. The call
+// counter lives as long as the compiled route, so it restarts when the config
+// is reloaded but not when other routes change at runtime.
+type responseSelector struct {
+	mode      string
+	responses []*cannedResponse
+	calls     atomic.Uint64
+}
+
+// compileResponses builds the responses of a route. A route without a
+// responses list has a single response made of its own fields.
+func compileResponses(route Route) (*responseSelector, error) {
+	entries := route.Responses
+	if len(entries) == 0 {
+		entries = []ResponseEntry{{
+			Response:   route.Response,
+			Body:       route.Body,
+			BodyBase64: route.BodyBase64,
+		}}
+	}
+
+	sel := &responseSelector{mode: route.ResponseMode}
+	for _, entry := range entries {
+		// Entry headers take precedence over the route's
+		headers := make(map[string]string, len(route.Headers)+len(entry.Headers))
+		for name, value := range route.Headers {
+			headers[name] = value
+		}
+		for name, value := range entry.Headers {
+			headers[name] = value
+		}
+
+		resp := &cannedResponse{
+			status:      entry.Status,
+			delay:       entry.Delay,
+			headers:     headers,
+			contentType: jsonContentType,
+			body:        entry.Response,
+		}
+
+		switch {
+		case entry.BodyBase64 != "":
+			data, err := base64.StdEncoding.DecodeString(entry.BodyBase64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bodyBase64: %w", err)
+			}
+			resp.body, resp.raw, resp.contentType = data, true, binaryContentType
+		case entry.Body != "":
+			resp.body, resp.raw, resp.contentType = entry.Body, true, textContentType
+		}
+
+		// An explicit content type wins, the entry's over the route's
+		if route.ContentType != "" {
+			resp.contentType, resp.explicitType = route.ContentType, true
+		}
+		if entry.ContentType != "" {
+			resp.contentType, resp.explicitType = entry.ContentType, true
+		}
+		if resp.status == 0 {
+			resp.status = route.Status
+		}
+		if resp.status == 0 {
+			resp.status = 200 // default status
+		}
+		if resp.delay == 0 {
+			resp.delay = route.Delay
+		}
+
+		// Binary bodies are never templates
+		if route.Template {
+			var err error
+			if _, binary := resp.body.([]byte); !binary {
+				compile := render.Compile
+				if resp.raw {
+					compile = render.CompileText
+				}
+				if resp.bodyTmpl, err = compile(resp.body); err != nil {
+					return nil, err
+				}
+			}
+			if resp.headersTmpl, err = render.CompileText(headersToValue(headers)); err != nil {
+				return nil, err
+			}
+		}
+
+		sel.responses = append(sel.responses, resp)
+	}
+
+	return sel, nil
+}
+
+// next returns the response for the next call
+func (sel *responseSelector) next() *cannedResponse {
+	n := uint64(len(sel.responses))
+	if n == 1 {
+		return sel.responses[0]
+	}
+
+	switch sel.mode {
+	case "cycle":
+		return sel.responses[(sel.calls.Add(1)-1)%n]
+	case "random":
+		return sel.responses[render.RandIntN(int(n))]
+	default: // sequence
+		call := sel.calls.Add(1) - 1
+		if call >= n {
+			call = n - 1
+		}
+		return sel.responses[call]
+	}
+}
+
+// render returns the body and headers of the response for a request
+func (resp *cannedResponse) render(r *http.Request) (interface{}, map[string]string, error) {
+	if resp.headersTmpl == nil {
+		return resp.body, resp.headers, nil
+	}
+
+	data := templateData(r)
+	body := resp.body
+	if resp.bodyTmpl != nil {
+		var err error
+		if body, err = resp.bodyTmpl.Render(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rendered, err := resp.headersTmpl.Render(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	headers := make(map[string]string)
+	for name, value := range rendered.(map[string]interface{}) {
+		headers[name], _ = value.(string)
+	}
+
+	return body, headers, nil
+}
+
+// headersToValue converts headers to a value that can be compiled as a
+// template
+func headersToValue(headers map[string]string) map[string]interface{} {
+	value := make(map[string]interface{}, len(headers))
+	for name, v := range headers {
+		value[name] = v
+	}
+	return value
+}
+
+// bodyBytes returns a raw body as bytes
+func bodyBytes(body interface{}) []byte {
+	switch b := body.(type) {
+	case []byte:
+		return b
+	case string:
+		return []byte(b)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/abdillahi-nur/mockr/internal/pathpattern"
+)
+
+// routeEntry is a configured route compiled for matching. Exactly one of
+// pattern and regex is set.
+type routeEntry struct {
+	route   Route
+	method  string
+	pattern *pathpattern.Pattern
+	regex   *regexp.Regexp
+	matcher *requestMatcher
+	handler http.HandlerFunc
+}
+
+// matchPath matches the request path and returns the captured parameters.
+// Named groups of a path regex are captured like pattern parameters.
+func (e *routeEntry) matchPath(path string) (map[string]string, bool) {
+	if e.regex == nil {
+		return e.pattern.Match(path)
+	}
+
+	groups := e.regex.FindStringSubmatch(path)
+	if groups == nil {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, name := range e.regex.SubexpNames() {
+		if i == 0 || name == "" {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = groups[i]
+	}
+	return params, true
+}
+
+// specificity returns the number of conditions a request must meet beyond
+// method and path: request matchers and a required scenario state
+func (e *routeEntry) specificity() int {
+	n := e.matcher.count()
+	if e.route.RequiredState != "" {
+		n++
+	}
+	return n
+}
+
+// compareEntries orders entries by precedence: higher priority first, then
+// path patterns before regexes, then more specific patterns, then routes with
+// more conditions
+func compareEntries(a, b *routeEntry) int {
+	if a.route.Priority != b.route.Priority {
+		return b.route.Priority - a.route.Priority
+	}
+	if (a.regex == nil) != (b.regex == nil) {
+		if a.regex == nil {
+			return -1
+		}
+		return 1
+	}
+	if a.regex == nil {
+		if diff := pathpattern.Compare(a.pattern, b.pattern); diff != 0 {
+			return diff
+		}
+	}
+	return b.specificity() - a.specificity()
+}
+
+// router dispatches requests to routes by method and path pattern
+type router struct {
+	entries  []*routeEntry
+	notFound http.HandlerFunc
+	notAllow func(w http.ResponseWriter, r *http.Request, allow string)
+	state    func(scenario string) string
+}
+
+// compileRoutes compiles routes for matching. Routes whose method, path
+// pattern, matchers or responses are invalid are skipped and returned as
+// errors.
+func (s *Server) compileRoutes(routes []Route) ([]*routeEntry, []error) {
+	var entries []*routeEntry
+	var errs []error
+	for _, route := range routes {
+		entry, err := s.compileRoute(route)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, errs
+}
+
+// compileRoute compiles a route for matching. Each compiled route counts its
+// own calls, so a route compiled once keeps its place in its responses.
+func (s *Server) compileRoute(route Route) (*routeEntry, error) {
+	method := strings.ToUpper(route.Method)
+	switch method {
+	case "GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
+	default:
+		return nil, fmt.Errorf("unsupported method '%s' for route '%s'", route.Method, route.Path)
+	}
+
+	handler, err := s.createHandler(route)
+	if err != nil {
+		return nil, fmt.Errorf("route '%s': %w", route.displayPath(), err)
+	}
+
+	entry := &routeEntry{
+		route:   route,
+		method:  method,
+		handler: handler,
+	}
+
+	if route.Match != nil && route.Match.PathRegex != "" {
+		entry.regex, err = regexp.Compile(route.Match.PathRegex)
+	} else {
+		entry.pattern, err = pathpattern.Parse(route.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid path '%s': %w", route.displayPath(), err)
+	}
+
+	entry.matcher, err = compileMatch(route.Match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher for route '%s': %w", route.displayPath(), err)
+	}
+
+	return entry, nil
+}
+
+// newRouter builds a router from compiled routes, given in config order, and
+// resources
+func (s *Server) newRouter(entries []*routeEntry, store *resourceStore) *router {
+	rt := &router{
+		entries:  append([]*routeEntry(nil), entries...),
+		notFound: s.defaultHandler,
+		notAllow: s.methodNotAllowedHandler,
+		state:    s.scenarioState,
+	}
+
+	// Resource routes come after configured routes, so a configured route
+	// overrides the generated one for the same method and path
+	for _, base := range store.bases() {
+		rt.entries = append(rt.entries, s.resourceRoutes(store, base)...)
+	}
+
+	// Highest precedence first; config order breaks ties
+	sort.SliceStable(rt.entries, func(i, j int) bool {
+		return compareEntries(rt.entries[i], rt.entries[j]) < 0
+	})
+
+	return rt
+}
+
+// resourceEntry creates the router entry of a generated resource route
+func (s *Server) resourceEntry(method, path string, handler http.HandlerFunc) *routeEntry {
+	pattern, _ := pathpattern.Parse(path)
+	return &routeEntry{
+		route:   Route{Path: path, Method: method},
+		method:  method,
+		pattern: pattern,
+		matcher: &requestMatcher{},
+		handler: handler,
+	}
+}
+
+// ServeHTTP finds the route for the request method, path, matchers and
+// scenario state. Path parameters are exposed to handlers through
+// r.PathValue.
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	var headFallback *routeEntry
+	var headParams map[string]string
+	methodMatched := false
+
+	for _, entry := range rt.entries {
+		params, ok := entry.matchPath(r.URL.Path)
+		if !ok {
+			continue
+		}
+
+		allowed = appendMethod(allowed, entry.method)
+		if entry.method == "GET" {
+			allowed = appendMethod(allowed, "HEAD")
+		}
+
+		// HEAD is served by the GET route unless configured explicitly
+		isHeadFallback := r.Method == "HEAD" && entry.method == "GET"
+		if entry.method != r.Method && !isHeadFallback {
+			continue
+		}
+		methodMatched = true
+
+		if !entry.matcher.matches(r) {
+			continue
+		}
+
+		if entry.route.RequiredState != "" && rt.state(entry.route.Scenario) != entry.route.RequiredState {
+			continue
+		}
+
+		if entry.method == r.Method {
+			entry.handler(w, withPathParams(r, params))
+			return
+		}
+
+		if headFallback == nil {
+			headFallback = entry
+			headParams = params
+		}
+	}
+
+	if headFallback != nil {
+		headFallback.handler(w, withPathParams(r, headParams))
+		return
+	}
+
+	// A path that exists but not for this method is a 405. When the method
+	// exists but no route's matchers accept the request, it is a 404.
+	if len(allowed) > 0 && !methodMatched {
+		sort.Strings(allowed)
+		rt.notAllow(w, r, strings.Join(allowed, ", "))
+		return
+	}
+
+	rt.notFound(w, r)
+}
+
+// pathParamsContextKey is the context key of the captured path parameters
+type pathParamsContextKey struct{}
+
+// withPathParams exposes captured path parameters on the request, both
+// through r.PathValue and as a whole for response templates
+func withPathParams(r *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+	r = r.WithContext(context.WithValue(r.Context(), pathParamsContextKey{}, params))
+	for name, value := range params {
+		r.SetPathValue(name, value)
+	}
+	return r
+}
+
+// pathParams returns the path parameters captured for the request
+func pathParams(r *http.Request) map[string]string {
+	if params, ok := r.Context().Value(pathParamsContextKey{}).(map[string]string); ok {
+		return params
+	}
+	return map[string]string{}
+}
+
+// appendMethod adds a method to the list if it is not already present
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+	return append(methods, method)
+}
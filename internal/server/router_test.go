@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testRoute returns a route answering with its name in the X-Route header
+func testRoute(method, path, name string) Route {
+	return Route{Method: method, Path: path, Response: name, Headers: map[string]string{"X-Route": name}}
+}
+
+func TestRouterServeHTTP(t *testing.T) {
+	q := "x"
+	regex := testRoute("GET", "", "regex")
+	regex.Match = &Match{PathRegex: `^/v\d+/ping$`}
+	shadowed := testRoute("GET", "", "r-regex")
+	shadowed.Match = &Match{PathRegex: `^/r/\d+$`}
+	search := testRoute("GET", "/search", "search-x")
+	search.Match = &Match{Query: map[string]ValueMatcher{"q": {Equals: &q}}}
+	keyed := testRoute("POST", "/keyed", "keyed")
+	keyed.Match = &Match{Headers: map[string]ValueMatcher{"X-Key": {Equals: &q}}}
+	priority := testRoute("GET", "/p/{id}", "p-priority")
+	priority.Priority = 5
+
+	routes := []Route{
+		testRoute("GET", "/users/{id}", "user"),
+		testRoute("GET", "/users/me", "me"),
+		testRoute("GET", "/users", "list"),
+		testRoute("POST", "/users", "create"),
+		testRoute("DELETE", "/users/{id}", "delete"),
+		testRoute("GET", "/files/{path...}", "files"),
+		testRoute("GET", "/head", "head-get"),
+		testRoute("HEAD", "/head", "head"),
+		regex,
+		shadowed,
+		testRoute("GET", "/r/{id}", "r-pattern"),
+		testRoute("GET", "/search", "search"),
+		search,
+		keyed,
+		testRoute("GET", "/p/special", "p-literal"),
+		priority,
+		testRoute("GET", "/dup", "first"),
+		testRoute("GET", "/dup", "second"),
+	}
+
+	s := New(Config{}, "", 0, nil)
+	entries, errs := s.compileRoutes(routes)
+	if len(errs) > 0 {
+		t.Fatalf("compileRoutes() failed: %v", errs)
+	}
+	rt := s.newRouter(entries, newResourceStore(nil))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header http.Header
+		status int
+		route  string
+		allow  string
+	}{
+		{name: "literal before parameter", method: "GET", target: "/users/me", status: 200, route: "me"},
+		{name: "parameter", method: "GET", target: "/users/42", status: 200, route: "user"},
+		{name: "method on the same path", method: "POST", target: "/users", status: 200, route: "create"},
+		{name: "method on a parameter path", method: "DELETE", target: "/users/42", status: 200, route: "delete"},
+		{name: "wildcard", method: "GET", target: "/files/a/b.txt", status: 200, route: "files"},
+		{name: "HEAD falls back to GET", method: "HEAD", target: "/users/42", status: 200, route: "user"},
+		{name: "explicit HEAD wins", method: "HEAD", target: "/head", status: 200, route: "head"},
+		{name: "pattern before regex", method: "GET", target: "/r/1", status: 200, route: "r-pattern"},
+		{name: "regex", method: "GET", target: "/v2/ping", status: 200, route: "regex"},
+		{name: "regex not matched", method: "GET", target: "/v2/ping/", status: 404},
+		{name: "more matchers first", method: "GET", target: "/search?q=x", status: 200, route: "search-x"},
+		{name: "matchers not met", method: "GET", target: "/search?q=y", status: 200, route: "search"},
+		{name: "priority before specificity", method: "GET", target: "/p/special", status: 200, route: "p-priority"},
+		{name: "config order breaks ties", method: "GET", target: "/dup", status: 200, route: "first"},
+		{name: "405 lists the methods of the path", method: "PUT", target: "/users/me", status: 405, allow: "DELETE, GET, HEAD"},
+		{name: "405 on a literal path", method: "DELETE", target: "/users", status: 405, allow: "GET, HEAD, POST"},
+		{name: "405 for a method only another route has", method: "GET", target: "/keyed", status: 405, allow: "POST"},
+		{name: "404 when matchers reject the method", method: "POST", target: "/keyed", status: 404},
+		{name: "matcher on a header", method: "POST", target: "/keyed", header: http.Header{"X-Key": {"x"}}, status: 200, route: "keyed"},
+		{name: "unknown path", method: "GET", target: "/nope", status: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("X-Route"); got != tt.route {
+				t.Errorf("route = %q, want %q", got, tt.route)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// runtimeRoute is a route added through the admin routes API
+type runtimeRoute struct {
+	id    string
+	route Route
+
+	// entry is the compiled route, or nil until it is compiled
+	entry *routeEntry
+}
+
+// label returns the route as "METHOD path"
+func (rr runtimeRoute) label() string {
+	return strings.ToUpper(rr.route.Method) + " " + rr.route.displayPath()
+}
+
+// routeInfo is a route as listed by the admin routes API. Only runtime
+// routes have an id; config routes are managed through the config files.
+type routeInfo struct {
+	ID     string `json:"id,omitempty"`
+	Source string `json:"source"`
+	Route
+}
+
+// Sources of the routes listed by the admin routes API
+const (
+	sourceRuntime = "runtime"
+	sourceFile    = "file"
+)
+
+// SetRouteParser sets how routes sent to the admin routes API are decoded and
+// validated; routes are refused if it is not set. It must be called before
+// Start.
+func (s *Server) SetRouteParser(parse func(data []byte) (Route, error)) {
+	s.parseRoute = parse
+}
+
+// SetKeepRuntimeRoutes makes routes added through the admin routes API
+// survive config reloads instead of being cleared. It must be called before
+// Start.
+func (s *Server) SetKeepRuntimeRoutes(keep bool) {
+	s.keepRuntimeRoutes = keep
+}
+
+// servedEntries returns the compiled routes to serve, runtime routes before
+// config routes so that a runtime route wins over a config route with the
+// same method, path and matchers
+func servedEntries(runtimeRoutes []runtimeRoute, configEntries []*routeEntry) []*routeEntry {
+	entries := make([]*routeEntry, 0, len(runtimeRoutes)+len(configEntries))
+	for _, rr := range runtimeRoutes {
+		entries = append(entries, rr.entry)
+	}
+	return append(entries, configEntries...)
+}
+
+// compileRuntimeRoutes compiles the runtime routes that are not compiled yet
+// and returns the errors of those that are invalid
+func (s *Server) compileRuntimeRoutes(runtimeRoutes []runtimeRoute) []error {
+	var errs []error
+	for i := range runtimeRoutes {
+		rr := &runtimeRoutes[i]
+		if rr.entry != nil {
+			continue
+		}
+		entry, err := s.compileRoute(rr.route)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		rr.entry = entry
+	}
+	return errs
+}
+
+// setRuntimeRoutes swaps in a routing table serving the given runtime routes
+// with the current config routes and resources. Compiled routes are reused,
+// so routes left unchanged keep their place in their responses. Nothing
+// changes if a route is invalid. The caller must hold s.mu.
+func (s *Server) setRuntimeRoutes(runtimeRoutes []runtimeRoute) error {
+	if errs := s.compileRuntimeRoutes(runtimeRoutes); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	s.runtimeRoutes = runtimeRoutes
+	s.mux.Store(s.buildRoutes(servedEntries(runtimeRoutes, s.configEntries), s.config.DefaultHeaders, s.resources))
+	return nil
+}
+
+// findRuntimeRoute returns the index of the runtime route with the given id,
+// or -1. The caller must hold s.mu.
+func (s *Server) findRuntimeRoute(id string) int {
+	for i, rr := range s.runtimeRoutes {
+		if rr.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// routesHandler handles /__mockr/routes: GET lists all routes (filtered by
+// ?source=runtime or ?source=file), POST adds a runtime route and DELETE
+// removes all runtime routes
+func (s *Server) routesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		s.listRoutes(w, r)
+	case "POST":
+		if s.requireAdmin(w, r) {
+			s.createRoute(w, r)
+		}
+	case "DELETE":
+		if s.requireAdmin(w, r) {
+			s.clearRoutes(w)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+	}
+}
+
+// routeHandler handles /__mockr/routes/{id}: GET returns a runtime route,
+// PUT replaces it and DELETE removes it
+func (s *Server) routeHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		s.getRoute(w, r)
+	case "PUT":
+		if s.requireAdmin(w, r) {
+			s.updateRoute(w, r)
+		}
+	case "DELETE":
+		if s.requireAdmin(w, r) {
+			s.removeRoute(w, r)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+	}
+}
+
+// listRoutes handles GET /__mockr/routes, runtime routes first as they take
+// precedence
+func (s *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	source := r.URL.Query().Get("source")
+	if source != "" && source != sourceRuntime && source != sourceFile {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown source '%s'", source)})
+		return
+	}
+
+	s.mu.RLock()
+	routes := []routeInfo{}
+	if source != sourceFile {
+		for _, rr := range s.runtimeRoutes {
+			routes = append(routes, routeInfo{ID: rr.id, Source: sourceRuntime, Route: rr.route})
+		}
+	}
+	if source != sourceRuntime {
+		for _, route := range s.config.Routes {
+			routes = append(routes, routeInfo{Source: sourceFile, Route: route})
+		}
+	}
+	s.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"routes": routes})
+}
+
+// getRoute handles GET /__mockr/routes/{id}
+func (s *Server) getRoute(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	idx := s.findRuntimeRoute(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Route not found"})
+		return
+	}
+	rr := s.runtimeRoutes[idx]
+	writeJSON(w, http.StatusOK, routeInfo{ID: rr.id, Source: sourceRuntime, Route: rr.route})
+}
+
+// createRoute handles POST /__mockr/routes
+func (s *Server) createRoute(w http.ResponseWriter, r *http.Request) {
+	route, ok := s.decodeRoute(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rr := runtimeRoute{id: strconv.Itoa(s.lastRuntimeID + 1), route: route}
+	runtimeRoutes := append([]runtimeRoute{rr}, s.runtimeRoutes...)
+	if err := s.setRuntimeRoutes(runtimeRoutes); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid route: %v", err)})
+		return
+	}
+	s.lastRuntimeID++
+	log.Printf("Runtime route %s added: %s", rr.id, rr.label())
+
+	w.Header().Set("Location", "/__mockr/routes/"+rr.id)
+	writeJSON(w, http.StatusCreated, routeInfo{ID: rr.id, Source: sourceRuntime, Route: rr.route})
+}
+
+// updateRoute handles PUT /__mockr/routes/{id}, replacing the route
+func (s *Server) updateRoute(w http.ResponseWriter, r *http.Request) {
+	route, ok := s.decodeRoute(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.findRuntimeRoute(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Route not found"})
+		return
+	}
+
+	// Routes are never modified in place, so requests being served by the
+	// previous routing table are unaffected
+	rr := runtimeRoute{id: s.runtimeRoutes[idx].id, route: route}
+	runtimeRoutes := append([]runtimeRoute(nil), s.runtimeRoutes...)
+	runtimeRoutes[idx] = rr
+	if err := s.setRuntimeRoutes(runtimeRoutes); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid route: %v", err)})
+		return
+	}
+	log.Printf("Runtime route %s updated: %s", rr.id, rr.label())
+
+	writeJSON(w, http.StatusOK, routeInfo{ID: rr.id, Source: sourceRuntime, Route: rr.route})
+}
+
+// removeRoute handles DELETE /__mockr/routes/{id}
+func (s *Server) removeRoute(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.findRuntimeRoute(r.PathValue("id"))
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Route not found"})
+		return
+	}
+
+	rr := s.runtimeRoutes[idx]
+	runtimeRoutes := append(append([]runtimeRoute(nil), s.runtimeRoutes[:idx]...), s.runtimeRoutes[idx+1:]...)
+	if err := s.setRuntimeRoutes(runtimeRoutes); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Runtime route %s removed: %s", rr.id, rr.label())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearRoutes handles DELETE /__mockr/routes, removing all runtime routes
+func (s *Server) clearRoutes(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.setRuntimeRoutes(nil); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Runtime routes cleared")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeRoute parses the request body as a route, answering 400 when it is
+// not a valid one
+func (s *Server) decodeRoute(w http.ResponseWriter, r *http.Request) (Route, bool) {
+	if s.parseRoute == nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "Runtime routes are disabled"})
+		return Route{}, false
+	}
+	route, err := s.parseRoute(bufferedBody(r).raw)
+	if err == nil {
+		err = checkReservedPath(route)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid route: %v", err)})
+		return Route{}, false
+	}
+	return route, true
+}
+
+// checkReservedPath refuses runtime routes that would shadow the health
+// check or the admin endpoints
+func checkReservedPath(route Route) error {
+	if route.Match != nil && route.Match.PathRegex != "" {
+		return nil
+	}
+	if route.Path == "/health" || route.Path == "/__mockr" || strings.HasPrefix(route.Path, "/__mockr/") {
+		return fmt.Errorf("path '%s' is reserved", route.Path)
+	}
+	return nil
+}
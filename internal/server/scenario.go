@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// scenarioStarted is the initial state of every scenario
+const scenarioStarted = "Started"
+
+// scenarioStore holds the states of the scenarios that left their initial
+// state. It has its own lock, separate from Server.mu, so that requests
+// matching and moving scenarios never wait for a reload.
+type scenarioStore struct {
+	mu     sync.RWMutex
+	states map[string]string
+}
+
+// newScenarioStore creates a store with every scenario in its initial state
+func newScenarioStore() *scenarioStore {
+	return &scenarioStore{states: make(map[string]string)}
+}
+
+// scenarioState returns the current state of a scenario
+func (s *Server) scenarioState(name string) string {
+	s.scenarios.mu.RLock()
+	defer s.scenarios.mu.RUnlock()
+
+	if state, ok := s.scenarios.states[name]; ok {
+		return state
+	}
+	return scenarioStarted
+}
+
+// setScenarioState moves a scenario to a new state
+func (s *Server) setScenarioState(name, state string) {
+	s.scenarios.mu.Lock()
+	defer s.scenarios.mu.Unlock()
+
+	s.scenarios.states[name] = state
+}
+
+// resetScenarios moves all scenarios, or only the named one, back to their
+// initial state
+func (s *Server) resetScenarios(name string) {
+	s.scenarios.mu.Lock()
+	defer s.scenarios.mu.Unlock()
+
+	if name == "" {
+		s.scenarios.states = make(map[string]string)
+		return
+	}
+	delete(s.scenarios.states, name)
+}
+
+// scenarioStates returns the state of every scenario used by a config or
+// runtime route
+func (s *Server) scenarioStates() map[string]string {
+	states := make(map[string]string)
+	s.mu.RLock()
+	for _, rr := range s.runtimeRoutes {
+		if rr.route.Scenario != "" {
+			states[rr.route.Scenario] = scenarioStarted
+		}
+	}
+	for _, route := range s.config.Routes {
+		if route.Scenario != "" {
+			states[route.Scenario] = scenarioStarted
+		}
+	}
+	s.mu.RUnlock()
+
+	s.scenarios.mu.RLock()
+	defer s.scenarios.mu.RUnlock()
+	for name, state := range s.scenarios.states {
+		states[name] = state
+	}
+	return states
+}
+
+// scenariosHandler handles GET /__mockr/scenarios, listing scenario states
+func (s *Server) scenariosHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"scenarios": s.scenarioStates()})
+}
+
+// scenariosResetHandler handles POST /__mockr/scenarios/reset, moving all
+// scenarios (or the one given by ?name=) back to their initial state. It
+// requires the admin token when one is set.
+func (s *Server) scenariosResetHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	// Scenarios are reset freely unless an admin token is set
+	if s.adminToken != "" && !s.requireAdmin(w, r) {
+		return
+	}
+
+	s.resetScenarios(r.URL.Query().Get("name"))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"scenarios": s.scenarioStates()})
+}
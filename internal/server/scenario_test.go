@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScenariosReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		auth   string
+		status int
+		reset  bool
+	}{
+		{name: "no token set", status: 200, reset: true},
+		{name: "token given", token: "s3cret", auth: "Bearer s3cret", status: 200, reset: true},
+		{name: "token missing", token: "s3cret", status: 401},
+		{name: "wrong token", token: "s3cret", auth: "Bearer nope", status: 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(Config{}, "", 0, nil)
+			s.SetAdminToken(tt.token)
+			s.setScenarioState("checkout", "paid")
+
+			r := httptest.NewRequest("POST", "/__mockr/scenarios/reset", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			s.scenariosResetHandler(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			want := "paid"
+			if tt.reset {
+				want = scenarioStarted
+			}
+			if got := s.scenarioState("checkout"); got != want {
+				t.Errorf("state = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestScenarioStates(t *testing.T) {
+	s := New(Config{Routes: []Route{{Method: "GET", Path: "/cart", Scenario: "checkout"}}}, "", 0, nil)
+	s.runtimeRoutes = []runtimeRoute{{id: "1", route: Route{Method: "GET", Path: "/login", Scenario: "auth"}}}
+	s.setScenarioState("checkout", "paid")
+
+	w := httptest.NewRecorder()
+	s.scenariosHandler(w, httptest.NewRequest("GET", "/__mockr/scenarios", nil))
+
+	var body struct {
+		Scenarios map[string]string `json:"scenarios"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"checkout": "paid", "auth": scenarioStarted}
+	if !maps.Equal(body.Scenarios, want) {
+		t.Errorf("scenarios = %v, want %v", body.Scenarios, want)
+	}
+}
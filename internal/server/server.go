@@ -3,23 +3,72 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/abdillahi-nur/mockr/internal/render"
 	"golang.org/x/time/rate"
 )
 
 // Route represents a mock API route configuration
 type Route struct {
+	Path     string      `json:"path"`
 	Method   string      `json:"method"`
 	Status   int         `json:"status,omitempty"`
 	Delay    int         `json:"delay,omitempty"`
+	Priority int         `json:"priority,omitempty"`
+	Match    *Match      `json:"match,omitempty"`
+	Template bool        `json:"template,omitempty"`
 	Response interface{} `json:"response"`
+
+	Body        string `json:"body,omitempty"`
+	BodyBase64  string `json:"bodyBase64,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+
+	Headers map[string]string `json:"headers,omitempty"`
+
+	Responses    []ResponseEntry `json:"responses,omitempty"`
+	ResponseMode string          `json:"responseMode,omitempty"`
+	Collection   bool            `json:"collection,omitempty"`
+
+	Scenario      string `json:"scenario,omitempty"`
+	RequiredState string `json:"requiredState,omitempty"`
+	NewState      string `json:"newState,omitempty"`
+}
+
+// Config is everything the server serves: routes and CRUD resources
+type Config struct {
+	Routes []Route
+
+	// Resources maps resource base paths such as "/users" to their seed items
+	Resources map[string][]map[string]interface{}
+
+	// DefaultHeaders are added to every mock response
+	DefaultHeaders map[string]string
+}
+
+// displayPath returns the route path, or its regex prefixed with '~'
+func (r Route) displayPath() string {
+	if r.Match != nil && r.Match.PathRegex != "" {
+		return "~" + r.Match.PathRegex
+	}
+	return r.Path
+}
+
+// status returns the route status, 200 if none is set
+func (r Route) status() int {
+	if r.Status == 0 {
+		return 200
+	}
+	return r.Status
 }
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -35,24 +84,50 @@ func (rw *responseWriter) WriteHeader(code int) {
 
 // Server represents the mock HTTP server
 type Server struct {
-	config     map[string]Route
+	config     Config
 	host       string
 	port       int
 	mu         sync.RWMutex
-	mux        *http.ServeMux
-	onReload   func(map[string]Route)
+	onReload   func(Config)
 	httpServer *http.Server
 	limiter    *rate.Limiter
+	scenarios  *scenarioStore
+	resources  *resourceStore
+	persist    *dataPersister
+	status     reloadStatus
+
+	// adminToken authenticates admin requests that change the server, and
+	// reload loads the config again for the admin reload endpoint
+	adminToken string
+	reload     func() error
+
+	// runtimeRoutes are the routes added through the admin routes API. They
+	// are served before the config routes and, unless keepRuntimeRoutes is
+	// set, cleared when the config is reloaded. parseRoute decodes and
+	// validates the routes sent.
+	runtimeRoutes     []runtimeRoute
+	lastRuntimeID     int
+	keepRuntimeRoutes bool
+	parseRoute        func(data []byte) (Route, error)
+
+	// configEntries are the compiled config routes being served. They are
+	// reused when only runtime routes change, so that the config routes keep
+	// their place in their responses.
+	configEntries []*routeEntry
+
+	// mux is the current routing table. Reloads build a new one and swap it
+	// in atomically, so serving requests takes no locks.
+	mux atomic.Pointer[http.ServeMux]
 }
 
 // New creates a new mock server instance
-func New(config map[string]Route, host string, port int, onReload func(map[string]Route)) *Server {
+func New(config Config, host string, port int, onReload func(Config)) *Server {
 	return &Server{
-		config:   config,
-		host:     host,
-		port:     port,
-		mux:      http.NewServeMux(),
-		onReload: onReload,
+		config:    config,
+		host:      host,
+		port:      port,
+		onReload:  onReload,
+		scenarios: newScenarioStore(),
 	}
 }
 
@@ -63,14 +138,26 @@ func (s *Server) SetRateLimit(requestsPerSecond float64, burst int) {
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
+	s.mu.Lock()
 	s.registerRoutes()
+	s.status.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	// Restore resource collections saved by a previous run
+	if s.persist != nil {
+		if err := s.persist.load(s.resources); err != nil {
+			log.Printf("Warning: %v, using seed data", err)
+		} else {
+			log.Printf("Resource data file: %s", s.persist.path)
+		}
+	}
 
 	addr := fmt.Sprintf("%s:%d", s.host, s.port)
 
 	// Configure HTTP server with security timeouts
 	s.httpServer = &http.Server{
 		Addr:              addr,
-		Handler:           s.mux,
+		Handler:           s,
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       15 * time.Second,
 		WriteTimeout:      15 * time.Second,
@@ -78,11 +165,24 @@ func (s *Server) Start() error {
 	}
 
 	log.Printf("Starting mock server on %s", addr)
+	s.mu.RLock()
 	s.logRoutes()
+	s.mu.RUnlock()
 
 	return s.httpServer.ListenAndServe()
 }
 
+// ServeHTTP serves a request with the current routing table. Requests in
+// flight during a reload finish on the table they started with.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux := s.mux.Load()
+	if mux == nil {
+		http.Error(w, "Server not started", http.StatusServiceUnavailable)
+		return
+	}
+	mux.ServeHTTP(w, r)
+}
+
 // Shutdown gracefully shuts down the HTTP server
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.httpServer == nil {
@@ -90,7 +190,14 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	}
 
 	log.Println("Shutting down HTTP server...")
-	return s.httpServer.Shutdown(ctx)
+	err := s.httpServer.Shutdown(ctx)
+
+	// Save pending resource changes once no request can make new ones
+	if s.persist != nil {
+		s.persist.flush()
+	}
+
+	return err
 }
 
 // bodyLimitMiddleware wraps request bodies with size limits
@@ -99,15 +206,14 @@ func (s *Server) bodyLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		// Limit request body size to 1MB for security
 		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 
-		// Read and discard body to enforce size limit even if handler doesn't read it
-		if r.Method == "POST" || r.Method == "PUT" || r.Method == "PATCH" {
-			_, err := io.ReadAll(r.Body)
-			if err != nil {
-				w.Header().Set("Content-Type", "application/json; charset=utf-8")
-				w.WriteHeader(http.StatusRequestEntityTooLarge)
-				json.NewEncoder(w).Encode(map[string]string{"error": "request body too large"})
-				return
-			}
+		// Buffer the body once to enforce the size limit even if the handler
+		// doesn't read it, and to share it between matchers and handlers
+		r, err := withBufferedBody(r)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]string{"error": "request body too large"})
+			return
 		}
 
 		next(w, r)
@@ -135,6 +241,17 @@ func (s *Server) rateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// defaultHeadersMiddleware adds the configured default headers, which
+// handlers may override
+func (s *Server) defaultHeadersMiddleware(headers map[string]string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		next(w, r)
+	}
+}
+
 // loggingMiddleware logs request details
 func (s *Server) loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -161,98 +278,264 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// registerRoutes registers all routes with the mux
+// registerRoutes builds a routing table for the current config and swaps it
+// in for the one being served, skipping invalid routes. The caller must hold
+// s.mu.
 func (s *Server) registerRoutes() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	configEntries, errs := s.compileRoutes(s.config.Routes)
+	for _, err := range errs {
+		log.Printf("Warning: %v, skipping", err)
+	}
+	store := s.newStore(s.config.Resources)
+	s.configEntries = configEntries
+	s.resources = store
+	s.mux.Store(s.buildRoutes(servedEntries(s.runtimeRoutes, configEntries), s.config.DefaultHeaders, store))
+}
+
+// newStore creates a resource store holding the seed items, saved to the
+// data file after every change if there is one
+func (s *Server) newStore(resources map[string][]map[string]interface{}) *resourceStore {
+	store := newResourceStore(resources)
+	if s.persist != nil {
+		store.onChange = func() { s.persist.schedule(store) }
+	}
+	return store
+}
 
-	// Clear existing routes by creating new mux
-	s.mux = http.NewServeMux()
+// buildRoutes builds a routing table serving compiled routes, default headers
+// and a resource store without touching the one being served
+func (s *Server) buildRoutes(entries []*routeEntry, defaultHeaders map[string]string, store *resourceStore) *http.ServeMux {
+	// Build a new mux rather than changing the one being served
+	mux := http.NewServeMux()
 
 	// Always register /health endpoint first (no rate limiting, no delay, no status override)
-	s.mux.HandleFunc("/health", s.loggingMiddleware(s.bodyLimitMiddleware(s.healthHandler)))
-
-	// Register user-defined routes dynamically
-	for path, route := range s.config {
-		method := strings.ToUpper(route.Method)
-		switch method {
-		case "GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
-			// Apply all middlewares in order: rate limit → body limit → handler → logging
-			// Note: middleware wrapping is applied in reverse order
-			handler := s.createHandler(route)
-			handler = s.loggingMiddleware(handler)
-			handler = s.bodyLimitMiddleware(handler)
-			handler = s.rateLimitMiddleware(handler)
-			s.mux.HandleFunc(path, handler)
-		default:
-			log.Printf("Warning: Unsupported method '%s' for route '%s', skipping", route.Method, path)
-		}
+	mux.HandleFunc("/health", s.loggingMiddleware(s.bodyLimitMiddleware(s.healthHandler)))
+
+	// Admin endpoints to inspect the server and inspect and reset scenario states
+	mux.HandleFunc("/__mockr/status", s.loggingMiddleware(s.bodyLimitMiddleware(s.statusHandler)))
+	mux.HandleFunc("/__mockr/scenarios", s.loggingMiddleware(s.bodyLimitMiddleware(s.scenariosHandler)))
+	mux.HandleFunc("/__mockr/scenarios/reset", s.loggingMiddleware(s.bodyLimitMiddleware(s.scenariosResetHandler)))
+	if s.adminReloadEnabled() {
+		mux.HandleFunc("/__mockr/reload", s.loggingMiddleware(s.bodyLimitMiddleware(s.reloadHandler)))
 	}
-
-	// Add a default route for unregistered paths
-	defaultHandler := s.defaultHandler
-	defaultHandler = s.loggingMiddleware(defaultHandler)
-	defaultHandler = s.bodyLimitMiddleware(defaultHandler)
-	defaultHandler = s.rateLimitMiddleware(defaultHandler)
-	s.mux.HandleFunc("/", defaultHandler)
+	mux.HandleFunc("/__mockr/routes", s.loggingMiddleware(s.bodyLimitMiddleware(s.routesHandler)))
+	mux.HandleFunc("/__mockr/routes/{id}", s.loggingMiddleware(s.bodyLimitMiddleware(s.routeHandler)))
+
+	// Route user-defined routes by method and path pattern. All user routes,
+	// 405s and 404s share one handler, so the middlewares wrap the router.
+	// Apply all middlewares in order: rate limit → body limit → logging → default headers → handler
+	// Note: middleware wrapping is applied in reverse order
+	rt := s.newRouter(entries, store)
+	handler := rt.ServeHTTP
+	handler = s.defaultHeadersMiddleware(defaultHeaders, handler)
+	handler = s.loggingMiddleware(handler)
+	handler = s.bodyLimitMiddleware(handler)
+	handler = s.rateLimitMiddleware(handler)
+	mux.HandleFunc("/", handler)
+
+	return mux
 }
 
-// ReloadConfig updates the server configuration and re-registers routes
-func (s *Server) ReloadConfig(newConfig map[string]Route) {
+// ReloadConfig replaces the server configuration and swaps in routes for it.
+// Reloads are transactional: if any route of the new config is invalid, the
+// current config keeps being served and an error is returned. Concurrent
+// reloads and changes to runtime routes are applied one at a time.
+func (s *Server) ReloadConfig(newConfig Config) error {
 	s.mu.Lock()
+
+	// Kept runtime routes are compiled again, so that their responses start
+	// over like those of the config routes
+	var runtimeRoutes []runtimeRoute
+	if s.keepRuntimeRoutes {
+		for _, rr := range s.runtimeRoutes {
+			runtimeRoutes = append(runtimeRoutes, runtimeRoute{id: rr.id, route: rr.route})
+		}
+	}
+	configEntries, errs := s.compileRoutes(newConfig.Routes)
+	errs = append(errs, s.compileRuntimeRoutes(runtimeRoutes)...)
+	if len(errs) > 0 {
+		s.mu.Unlock()
+		err := fmt.Errorf("%d invalid routes: %w", len(errs), errors.Join(errs...))
+		s.ReportReloadFailure(err)
+		return err
+	}
+
+	for _, rr := range s.runtimeRoutes[len(runtimeRoutes):] {
+		log.Printf("Runtime route %s removed by reload: %s", rr.id, rr.label())
+	}
+	// Resources start over from their seed items on every reload
+	store := s.newStore(newConfig.Resources)
 	s.config = newConfig
+	s.configEntries = configEntries
+	s.runtimeRoutes = runtimeRoutes
+	s.resetScenarios("")
+	s.resources = store
+	s.mux.Store(s.buildRoutes(servedEntries(runtimeRoutes, configEntries), newConfig.DefaultHeaders, store))
+	s.status.reloaded()
+	s.logRoutes()
 	s.mu.Unlock()
 
-	s.registerRoutes()
-	s.logRoutes()
+	// Reloaded resources start over from their seed items; save that state
+	if s.persist != nil {
+		s.persist.schedule(store)
+	}
 
 	if s.onReload != nil {
 		s.onReload(newConfig)
 	}
+	return nil
 }
 
-// logRoutes logs the current routes
+// logRoutes logs the current routes. The caller must hold s.mu.
 func (s *Server) logRoutes() {
 	log.Printf("Available routes:")
 
 	// Always log /health endpoint
 	log.Printf("  /health [GET] -> Status: 200 (health check)")
+	log.Printf("  /__mockr/status [GET] -> server and reload status")
+	log.Printf("  /__mockr/scenarios [GET] -> scenario states")
+	if s.adminToken != "" {
+		log.Printf("  /__mockr/scenarios/reset [POST] -> reset scenario states (admin token required)")
+	} else {
+		log.Printf("  /__mockr/scenarios/reset [POST] -> reset scenario states")
+	}
+	if s.adminReloadEnabled() {
+		log.Printf("  /__mockr/reload [POST] -> reload the config (admin token required)")
+	}
+	if s.adminToken != "" {
+		log.Printf("  /__mockr/routes [GET, POST, DELETE] -> list and manage runtime routes (admin token required to change)")
+	} else {
+		log.Printf("  /__mockr/routes [GET] -> list routes")
+	}
 
-	// Log user-defined routes
-	for path, route := range s.config {
-		status := route.Status
-		if status == 0 {
-			status = 200
-		}
-		log.Printf("  %s [%s] -> Status: %d", path, route.Method, status)
+	// Log user-defined routes, runtime routes first as they take precedence
+	for _, rr := range s.runtimeRoutes {
+		log.Printf("  %s [%s] -> Status: %d (runtime route %s)", rr.route.displayPath(), strings.ToUpper(rr.route.Method), rr.route.status(), rr.id)
+	}
+	for _, route := range s.config.Routes {
+		log.Printf("  %s [%s] -> Status: %d", route.displayPath(), strings.ToUpper(route.Method), route.status())
+	}
+
+	// Log resources
+	for _, base := range slices.Sorted(maps.Keys(s.config.Resources)) {
+		log.Printf("  %s, %s/{id} [CRUD] -> %d items", base, base, len(s.config.Resources[base]))
 	}
 }
 
-// createHandler creates an HTTP handler for a specific route
-func (s *Server) createHandler(route Route) http.HandlerFunc {
+// createHandler creates an HTTP handler for a specific route. Templated
+// responses are compiled once here and rendered per request.
+func (s *Server) createHandler(route Route) (http.HandlerFunc, error) {
+	responses, err := compileResponses(route)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response template: %w", err)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Move the route's scenario on as soon as the route is hit
+		if route.NewState != "" {
+			s.setScenarioState(route.Scenario, route.NewState)
+		}
+
+		resp := responses.next()
+
 		// Apply delay if configured (before setting headers)
-		if route.Delay > 0 {
-			time.Sleep(time.Duration(route.Delay) * time.Millisecond)
+		if resp.delay > 0 {
+			time.Sleep(time.Duration(resp.delay) * time.Millisecond)
+		}
+
+		// Render templates before anything is written so errors can still
+		// turn into a 500
+		body, headers, err := resp.render(r)
+		if err != nil {
+			log.Printf("Error rendering response template for route %s: %v", r.URL.Path, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
 		}
 
-		// Set content type to JSON with charset (before status code)
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// Filter, sort and paginate array responses on request
+		if items, ok := body.([]interface{}); ok && route.Collection && !resp.raw {
+			if body, err = queryCollection(w, r, items); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+
+		// Set content type (before status code) unless the default headers
+		// set one; route headers may override it
+		if resp.explicitType || w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", resp.contentType)
+		}
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
 
 		// Set status code
-		status := route.Status
-		if status == 0 {
-			status = 200 // default status
+		w.WriteHeader(resp.status)
+
+		// Raw bodies are sent as is
+		if resp.raw {
+			if _, err := w.Write(bodyBytes(body)); err != nil {
+				log.Printf("Error writing response for route %s: %v", r.URL.Path, err)
+			}
+			return
 		}
-		w.WriteHeader(status)
 
 		// Marshal and write response
-		if err := json.NewEncoder(w).Encode(route.Response); err != nil {
+		if err := json.NewEncoder(w).Encode(body); err != nil {
 			log.Printf("Error encoding response for route %s: %v", r.URL.Path, err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+	}, nil
+}
+
+// templateData collects the request data available to response templates
+func templateData(r *http.Request) *render.Data {
+	data := &render.Data{
+		Method:  r.Method,
+		URL:     r.URL.String(),
+		Path:    pathParams(r),
+		Query:   make(map[string]string),
+		Headers: make(map[string]string),
+		Cookies: make(map[string]string),
+	}
+
+	for name, values := range r.URL.Query() {
+		data.Query[name] = values[0]
+	}
+	for name, values := range r.Header {
+		data.Headers[name] = values[0]
+	}
+	for _, c := range r.Cookies() {
+		if _, ok := data.Cookies[c.Name]; !ok {
+			data.Cookies[c.Name] = c.Value
+		}
+	}
+
+	body := bufferedBody(r)
+	data.RawBody = string(body.raw)
+	if doc, err := body.JSON(); err == nil {
+		data.Body = doc
+	}
+
+	return data
+}
+
+// methodNotAllowedHandler handles requests to a known path using a method
+// that is not configured for it
+func (s *Server) methodNotAllowedHandler(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+
+	response := map[string]interface{}{
+		"error":  "Method not allowed",
+		"path":   r.URL.Path,
+		"method": r.Method,
+		"allow":  allow,
 	}
+
+	json.NewEncoder(w).Encode(response)
 }
 
 // defaultHandler handles requests to unregistered routes
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// reloadStatus tracks the config being served and the outcome of reloads.
+// It is guarded by Server.mu.
+type reloadStatus struct {
+	// loadedAt is when the config being served was applied
+	loadedAt time.Time
+
+	reloads       int
+	failedReloads int
+
+	// lastReloadAt is when the last reload was attempted, and lastError why
+	// it failed, or "" if it succeeded
+	lastReloadAt time.Time
+	lastError    string
+}
+
+// reloaded records a successful reload
+func (st *reloadStatus) reloaded() {
+	st.reloads++
+	st.loadedAt = time.Now()
+	st.lastReloadAt = st.loadedAt
+	st.lastError = ""
+}
+
+// ReportReloadFailure records a reload that failed, e.g. because the config
+// could not be loaded, so that it shows in the status endpoint. The current
+// config keeps being served.
+func (s *Server) ReportReloadFailure(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.failedReloads++
+	s.status.lastReloadAt = time.Now()
+	s.status.lastError = err.Error()
+}
+
+// statusHandler handles GET /__mockr/status, reporting the config being
+// served and whether the last reload failed
+func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	s.mu.RLock()
+	status := map[string]interface{}{
+		"status":        "ok",
+		"loadedAt":      s.status.loadedAt.UTC().Format(time.RFC3339),
+		"routes":        len(s.config.Routes),
+		"resources":     len(s.config.Resources),
+		"runtimeRoutes": len(s.runtimeRoutes),
+		"reloads":       s.status.reloads,
+		"failedReloads": s.status.failedReloads,
+	}
+	if !s.status.lastReloadAt.IsZero() {
+		lastReload := map[string]interface{}{
+			"at": s.status.lastReloadAt.UTC().Format(time.RFC3339),
+			"ok": s.status.lastError == "",
+		}
+		if s.status.lastError != "" {
+			status["status"] = "reload_failed"
+			lastReload["error"] = s.status.lastError
+		}
+		status["lastReload"] = lastReload
+	}
+	s.mu.RUnlock()
+
+	writeJSON(w, http.StatusOK, status)
+}